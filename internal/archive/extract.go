@@ -0,0 +1,284 @@
+// Package archive reads and writes the archives that Trayscale uses
+// to send directories with Taildrop.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	// ErrUnsafePath is returned when an archive contains an entry that
+	// would be written outside of the destination directory.
+	ErrUnsafePath = errors.New("archive entry has unsafe path")
+
+	// ErrTooLarge is returned when an archive exceeds the limits
+	// given to Extract.
+	ErrTooLarge = errors.New("archive exceeds extraction limits")
+)
+
+// FormatOf returns the format of the archive called name based on its
+// extension. If name isn't named like an archive created by Trayscale,
+// as returned by Format.FileName, it returns false.
+func FormatOf(name string) (Format, bool) {
+	base := filepath.Base(name)
+	for _, f := range Formats {
+		suffix := Marker + f.Ext()
+		if strings.HasSuffix(base, suffix) && len(base) > len(suffix) {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+// IsArchive reports whether name looks like a directory archive that
+// was created by Trayscale.
+func IsArchive(name string) bool {
+	_, ok := FormatOf(name)
+	return ok
+}
+
+// DirName returns the name of the directory that the archive called
+// name should be extracted into. If name isn't named like an archive
+// created by Trayscale, it is returned unchanged.
+func DirName(name string) string {
+	base := filepath.Base(name)
+	f, ok := FormatOf(base)
+	if !ok {
+		return base
+	}
+	return strings.TrimSuffix(base, Marker+f.Ext())
+}
+
+// Limits restricts how much data Extract is willing to write so that
+// a small, highly compressed archive can't fill up the disk.
+type Limits struct {
+	// MaxBytes is the maximum total size of all extracted files.
+	MaxBytes int64
+
+	// MaxFiles is the maximum number of entries, files and directories
+	// combined, in the archive.
+	MaxFiles int
+}
+
+// Stats describes the result of an extraction.
+type Stats struct {
+	Files   int
+	Dirs    int
+	Bytes   int64
+	Skipped int
+}
+
+// maxWindow is the largest zstd window that Extract will accept. The
+// default encoder settings use far less than this.
+const maxWindow = 64 << 20
+
+// Extract unpacks the archive in the given format read from r into
+// dir, which must already exist. Entries with absolute paths or paths
+// that would escape dir cause the whole extraction to fail with
+// ErrUnsafePath. Symbolic links, hard links, and special files are
+// skipped and counted in the returned Stats. If the archive exceeds
+// limits, ErrTooLarge is returned.
+//
+// Zip archives can't be read as a stream, so they are first copied to
+// a temporary file next to dir. The copy is limited to limits.MaxBytes
+// so that a huge archive can't fill the disk before the other limits
+// are checked.
+//
+// On error, dir may have been partially populated.
+func Extract(r io.Reader, format Format, dir string, limits Limits) (Stats, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer root.Close()
+
+	switch format {
+	case FormatTarZstd:
+		z, err := zstd.NewReader(r, zstd.WithDecoderMaxWindow(maxWindow), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return Stats{}, fmt.Errorf("create zstd reader: %w", err)
+		}
+		defer z.Close()
+		return extractTar(tar.NewReader(z), root, limits)
+
+	case FormatTarGzip:
+		z, err := gzip.NewReader(r)
+		if err != nil {
+			return Stats{}, fmt.Errorf("create gzip reader: %w", err)
+		}
+		defer z.Close()
+		return extractTar(tar.NewReader(z), root, limits)
+
+	case FormatTar:
+		return extractTar(tar.NewReader(r), root, limits)
+
+	case FormatZip:
+		return extractZip(r, filepath.Dir(dir), root, limits)
+
+	default:
+		return Stats{}, fmt.Errorf("unknown archive format %q", format)
+	}
+}
+
+// entryName checks that name, the slash-separated path of an entry in
+// an archive, is safe to extract and converts it to a local path.
+func entryName(name string) (string, error) {
+	local := filepath.FromSlash(strings.TrimSuffix(name, "/"))
+	if !filepath.IsLocal(local) {
+		return "", fmt.Errorf("%w: %q", ErrUnsafePath, name)
+	}
+	return local, nil
+}
+
+func extractTar(t *tar.Reader, root *os.Root, limits Limits) (Stats, error) {
+	var stats Stats
+	for {
+		hdr, err := t.Next()
+		if err != nil {
+			if err == io.EOF {
+				return stats, nil
+			}
+			return stats, fmt.Errorf("read tar header: %w", err)
+		}
+
+		if stats.Files+stats.Dirs+stats.Skipped >= limits.MaxFiles {
+			return stats, ErrTooLarge
+		}
+
+		name, err := entryName(hdr.Name)
+		if err != nil {
+			return stats, err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err := root.MkdirAll(name, 0o755)
+			if err != nil {
+				return stats, err
+			}
+			stats.Dirs++
+
+		case tar.TypeReg:
+			if hdr.Size < 0 || stats.Bytes+hdr.Size > limits.MaxBytes {
+				return stats, ErrTooLarge
+			}
+
+			n, err := extractFile(root, name, hdr.FileInfo().Mode(), t, limits.MaxBytes-stats.Bytes)
+			stats.Bytes += n
+			if err != nil {
+				return stats, err
+			}
+			stats.Files++
+
+		case tar.TypeXGlobalHeader:
+			// Metadata only.
+
+		default:
+			stats.Skipped++
+		}
+	}
+}
+
+func extractZip(r io.Reader, tmpDir string, root *os.Root, limits Limits) (Stats, error) {
+	tmp, err := os.CreateTemp(tmpDir, ".trayscale-zip-*")
+	if err != nil {
+		return Stats{}, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, io.LimitReader(r, limits.MaxBytes+1))
+	if err != nil {
+		return Stats{}, fmt.Errorf("copy zip archive: %w", err)
+	}
+	if size > limits.MaxBytes {
+		return Stats{}, ErrTooLarge
+	}
+
+	z, err := zip.NewReader(tmp, size)
+	if err != nil {
+		return Stats{}, fmt.Errorf("read zip archive: %w", err)
+	}
+	if len(z.File) > limits.MaxFiles {
+		return Stats{}, ErrTooLarge
+	}
+
+	var stats Stats
+	for _, f := range z.File {
+		name, err := entryName(f.Name)
+		if err != nil {
+			return stats, err
+		}
+
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			err := root.MkdirAll(name, 0o755)
+			if err != nil {
+				return stats, err
+			}
+			stats.Dirs++
+
+		case mode.IsRegular():
+			if f.UncompressedSize64 > uint64(limits.MaxBytes-stats.Bytes) {
+				return stats, ErrTooLarge
+			}
+
+			n, err := extractZipFile(root, name, f, limits.MaxBytes-stats.Bytes)
+			stats.Bytes += n
+			if err != nil {
+				return stats, err
+			}
+			stats.Files++
+
+		default:
+			stats.Skipped++
+		}
+	}
+	return stats, nil
+}
+
+func extractZipFile(root *os.Root, name string, f *zip.File, limit int64) (int64, error) {
+	r, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	return extractFile(root, name, f.Mode(), r, limit)
+}
+
+func extractFile(root *os.Root, name string, mode fs.FileMode, r io.Reader, limit int64) (int64, error) {
+	if dir := filepath.Dir(name); dir != "." {
+		err := root.MkdirAll(dir, 0o755)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	file, err := root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode.Perm()|0o600)
+	if err != nil {
+		return 0, err
+	}
+
+	// The tar reader already stops at the end of the entry, but the
+	// header's size can't be fully trusted to be small, so limit it
+	// independently.
+	n, copyErr := io.Copy(file, io.LimitReader(r, limit+1))
+	closeErr := file.Close()
+	if n > limit {
+		return n, ErrTooLarge
+	}
+	return n, errors.Join(copyErr, closeErr)
+}
@@ -0,0 +1,208 @@
+package archive_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"deedles.dev/trayscale/internal/archive"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+var testLimits = archive.Limits{MaxBytes: 1 << 20, MaxFiles: 100}
+
+type entry struct {
+	hdr  tar.Header
+	data string
+}
+
+func file(name, data string) entry {
+	return entry{hdr: tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: int64(len(data))}, data: data}
+}
+
+func dir(name string) entry {
+	return entry{hdr: tar.Header{Typeflag: tar.TypeDir, Name: name, Mode: 0o755}}
+}
+
+func makeArchive(t *testing.T, entries ...entry) *bytes.Buffer {
+	var buf bytes.Buffer
+	z, err := zstd.NewWriter(&buf)
+	require.NoError(t, err)
+	w := tar.NewWriter(z)
+	for _, e := range entries {
+		require.NoError(t, w.WriteHeader(&e.hdr))
+		_, err := w.Write([]byte(e.data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, z.Close())
+	return &buf
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		format archive.Format
+		ok     bool
+	}{
+		{name: "photos.trayscale.tar.zst", format: archive.FormatTarZstd, ok: true},
+		{name: "/some/path/photos.trayscale.tar.zst", format: archive.FormatTarZstd, ok: true},
+		{name: "photos.trayscale.tar.gz", format: archive.FormatTarGzip, ok: true},
+		{name: "photos.trayscale.zip", format: archive.FormatZip, ok: true},
+		{name: "photos.trayscale.tar", format: archive.FormatTar, ok: true},
+		{name: "photos.tar.zst"},
+		{name: "photos.tar.gz"},
+		{name: "photos.zip"},
+		{name: "photos.tar"},
+		{name: ".trayscale.tar.zst"},
+		{name: ".trayscale.zip"},
+		{name: "photos.trayscale"},
+		{name: "photos.zst"},
+		{name: "photos.gz"},
+		{name: "notes.txt"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			format, ok := archive.FormatOf(test.name)
+			require.Equal(t, test.ok, ok)
+			require.Equal(t, test.format, format)
+			require.Equal(t, test.ok, archive.IsArchive(test.name))
+		})
+	}
+}
+
+func TestDirName(t *testing.T) {
+	require.Equal(t, "photos", archive.DirName("photos.trayscale.tar.zst"))
+	require.Equal(t, "photos", archive.DirName("photos.trayscale.tar.gz"))
+	require.Equal(t, "photos", archive.DirName("photos.trayscale.zip"))
+	require.Equal(t, "photos", archive.DirName("photos.trayscale.tar"))
+	require.Equal(t, "photos", archive.DirName("../photos.trayscale.tar.zst"))
+	require.Equal(t, "my.project", archive.DirName("my.project.trayscale.tar.zst"))
+	require.Equal(t, "photos.tar.zst", archive.DirName("photos.tar.zst"))
+	require.Equal(t, "notes.txt", archive.DirName("notes.txt"))
+}
+
+func TestExtract(t *testing.T) {
+	r := makeArchive(t,
+		dir("sub/"),
+		file("top.txt", "top"),
+		file("sub/nested.txt", "nested"),
+		file("implicit/dir/file.txt", "implicit"),
+	)
+
+	dst := t.TempDir()
+	stats, err := archive.Extract(r, archive.FormatTarZstd, dst, testLimits)
+	require.NoError(t, err)
+	require.Equal(t, archive.Stats{Files: 3, Dirs: 1, Bytes: int64(len("top") + len("nested") + len("implicit"))}, stats)
+
+	for name, want := range map[string]string{
+		"top.txt":               "top",
+		"sub/nested.txt":        "nested",
+		"implicit/dir/file.txt": "implicit",
+	} {
+		got, err := os.ReadFile(filepath.Join(dst, filepath.FromSlash(name)))
+		require.NoError(t, err)
+		require.Equal(t, want, string(got))
+	}
+}
+
+func TestExtractTraversal(t *testing.T) {
+	for _, name := range []string{"../escape.txt", "sub/../../escape.txt", "/etc/passwd"} {
+		t.Run(name, func(t *testing.T) {
+			parent := t.TempDir()
+			dst := filepath.Join(parent, "dst")
+			require.NoError(t, os.Mkdir(dst, 0o755))
+
+			_, err := archive.Extract(makeArchive(t, file(name, "evil")), archive.FormatTarZstd, dst, testLimits)
+			require.ErrorIs(t, err, archive.ErrUnsafePath)
+			require.NoFileExists(t, filepath.Join(parent, "escape.txt"))
+		})
+	}
+}
+
+func TestExtractSkipsLinks(t *testing.T) {
+	outside := t.TempDir()
+	r := makeArchive(t,
+		entry{hdr: tar.Header{Typeflag: tar.TypeSymlink, Name: "link", Linkname: outside}},
+		entry{hdr: tar.Header{Typeflag: tar.TypeLink, Name: "hard", Linkname: "/etc/passwd"}},
+		file("link/evil.txt", "evil"),
+	)
+
+	dst := t.TempDir()
+	stats, err := archive.Extract(r, archive.FormatTarZstd, dst, testLimits)
+	require.NoError(t, err)
+	require.Equal(t, 2, stats.Skipped)
+	require.NoFileExists(t, filepath.Join(outside, "evil.txt"))
+
+	fi, err := os.Lstat(filepath.Join(dst, "link"))
+	require.NoError(t, err)
+	require.True(t, fi.IsDir(), "symlink should not have been created")
+}
+
+func TestExtractLimits(t *testing.T) {
+	t.Run("bytes", func(t *testing.T) {
+		big := string(bytes.Repeat([]byte{0}, 4096))
+		r := makeArchive(t, file("a", big), file("b", big))
+		_, err := archive.Extract(r, archive.FormatTarZstd, t.TempDir(), archive.Limits{MaxBytes: 6000, MaxFiles: 100})
+		require.ErrorIs(t, err, archive.ErrTooLarge)
+	})
+
+	t.Run("files", func(t *testing.T) {
+		r := makeArchive(t, file("a", "a"), file("b", "b"), file("c", "c"))
+		_, err := archive.Extract(r, archive.FormatTarZstd, t.TempDir(), archive.Limits{MaxBytes: 1 << 20, MaxFiles: 2})
+		require.ErrorIs(t, err, archive.ErrTooLarge)
+	})
+
+	t.Run("zip input", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := zip.NewWriter(&buf)
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: "a", Method: zip.Store})
+		require.NoError(t, err)
+		_, err = fw.Write(bytes.Repeat([]byte{0}, 8192))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		parent := t.TempDir()
+		dst := filepath.Join(parent, "dst")
+		require.NoError(t, os.Mkdir(dst, 0o755))
+
+		_, err = archive.Extract(&buf, archive.FormatZip, dst, archive.Limits{MaxBytes: 4096, MaxFiles: 100})
+		require.ErrorIs(t, err, archive.ErrTooLarge)
+
+		entries, err := os.ReadDir(parent)
+		require.NoError(t, err)
+		require.Len(t, entries, 1, "temporary copy should have been removed")
+	})
+}
+
+func TestExtractNotArchive(t *testing.T) {
+	for _, format := range archive.Formats {
+		t.Run(string(format), func(t *testing.T) {
+			_, err := archive.Extract(bytes.NewReader([]byte("definitely not an archive")), format, t.TempDir(), testLimits)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestExtractZipTraversal(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	fw, err := w.Create("../escape.txt")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte("evil"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	parent := t.TempDir()
+	dst := filepath.Join(parent, "dst")
+	require.NoError(t, os.Mkdir(dst, 0o755))
+
+	_, err = archive.Extract(&buf, archive.FormatZip, dst, testLimits)
+	require.ErrorIs(t, err, archive.ErrUnsafePath)
+	require.NoFileExists(t, filepath.Join(parent, "escape.txt"))
+}
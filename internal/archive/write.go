@@ -0,0 +1,260 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrSizeUnknown is returned by Size for formats whose size can't be
+// known without actually compressing the data.
+var ErrSizeUnknown = errors.New("archive size can't be known in advance")
+
+// Format is an archive format that directories can be packed into.
+type Format string
+
+const (
+	FormatTarZstd Format = "tar.zst"
+	FormatTarGzip Format = "tar.gz"
+	FormatZip     Format = "zip"
+	FormatTar     Format = "tar"
+)
+
+// Formats lists every supported format, default first.
+var Formats = []Format{FormatTarZstd, FormatTarGzip, FormatZip, FormatTar}
+
+// ParseFormat returns the Format named by str. Unrecognized names
+// return FormatTarZstd and false.
+func ParseFormat(str string) (Format, bool) {
+	for _, f := range Formats {
+		if string(f) == str {
+			return f, true
+		}
+	}
+	return FormatTarZstd, false
+}
+
+// Ext returns the file extension, including the leading dot, of
+// archives in the format.
+func (f Format) Ext() string {
+	return "." + string(f)
+}
+
+// Marker goes between the name of a directory and the extension in the
+// names of the archives that Trayscale creates for it, such as
+// "photos.trayscale.tar.zst". Only archives with it are extracted, so
+// that archives that were sent as ordinary files are saved as they
+// are.
+const Marker = ".trayscale"
+
+// FileName returns the name of an archive in the format of the
+// directory called dir, including Marker.
+func (f Format) FileName(dir string) string {
+	return dir + Marker + f.Ext()
+}
+
+// Options configure how a directory is archived.
+type Options struct {
+	Format Format
+
+	// Exclude is a list of path.Match patterns. Any file or directory
+	// whose name or slash-separated path relative to the root matches
+	// one of them is left out of the archive, along with everything
+	// under it.
+	Exclude []string
+}
+
+func (opts Options) excluded(p string) bool {
+	for _, pattern := range opts.Exclude {
+		if ok, _ := path.Match(pattern, path.Base(p)); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// walk calls f for every directory and regular file in fsys that isn't
+// excluded by opts, parents before children. Symbolic links and other
+// special files are skipped.
+func walk(fsys fs.FS, opts Options, f func(p string, info fs.FileInfo) error) error {
+	return fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == "." {
+			return nil
+		}
+
+		if opts.excluded(p) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+
+		return f(p, info)
+	})
+}
+
+// Write writes an archive of the contents of fsys to w in the format
+// specified by opts.
+func Write(w io.Writer, fsys fs.FS, opts Options) error {
+	switch opts.Format {
+	case FormatTarZstd:
+		z, err := zstd.NewWriter(w)
+		if err != nil {
+			return err
+		}
+		return errors.Join(writeTar(z, fsys, opts), z.Close())
+
+	case FormatTarGzip:
+		z := gzip.NewWriter(w)
+		return errors.Join(writeTar(z, fsys, opts), z.Close())
+
+	case FormatTar:
+		return writeTar(w, fsys, opts)
+
+	case FormatZip:
+		return writeZip(w, fsys, opts)
+
+	default:
+		return fmt.Errorf("unknown archive format %q", opts.Format)
+	}
+}
+
+// Size returns the exact number of bytes that Write will produce for
+// fsys, provided that its contents don't change in between. Only
+// FormatTar is supported. Other formats return ErrSizeUnknown.
+func Size(fsys fs.FS, opts Options) (int64, error) {
+	if opts.Format != FormatTar {
+		return 0, ErrSizeUnknown
+	}
+
+	var c counter
+	tw := tar.NewWriter(&c)
+	err := walk(fsys, opts, func(p string, info fs.FileInfo) error {
+		hdr, err := tarHeader(p, info)
+		if err != nil {
+			return err
+		}
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+
+		// The tar writer pads the file's contents itself, so only the
+		// length matters.
+		_, err = io.CopyN(tw, zeros{}, hdr.Size)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	err = tw.Close()
+	return c.n, err
+}
+
+func tarHeader(p string, info fs.FileInfo) (*tar.Header, error) {
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return nil, err
+	}
+	hdr.Name = p
+	if info.IsDir() {
+		hdr.Name += "/"
+	}
+	return hdr, nil
+}
+
+func writeTar(w io.Writer, fsys fs.FS, opts Options) error {
+	tw := tar.NewWriter(w)
+	err := walk(fsys, opts, func(p string, info fs.FileInfo) error {
+		hdr, err := tarHeader(p, info)
+		if err != nil {
+			return err
+		}
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		return copyFile(tw, fsys, p)
+	})
+	return errors.Join(err, tw.Close())
+}
+
+func writeZip(w io.Writer, fsys fs.FS, opts Options) error {
+	zw := zip.NewWriter(w)
+	err := walk(fsys, opts, func(p string, info fs.FileInfo) error {
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = p
+		if info.IsDir() {
+			hdr.Name += "/"
+		} else {
+			hdr.Method = zip.Deflate
+		}
+
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		return copyFile(fw, fsys, p)
+	})
+	return errors.Join(err, zw.Close())
+}
+
+func copyFile(w io.Writer, fsys fs.FS, p string) error {
+	file, err := fsys.Open(p)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(w, file)
+	return err
+}
+
+type counter struct {
+	n int64
+}
+
+func (c *counter) Write(data []byte) (int, error) {
+	c.n += int64(len(data))
+	return len(data), nil
+}
+
+type zeros struct{}
+
+func (zeros) Read(buf []byte) (int, error) {
+	clear(buf)
+	return len(buf), nil
+}
@@ -0,0 +1,184 @@
+package archive_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"deedles.dev/trayscale/internal/archive"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+var testFS = fstest.MapFS{
+	"README.md":               {Data: []byte("# Example\n"), Mode: 0o644},
+	"src/main.go":             {Data: []byte("package main\n"), Mode: 0o644},
+	"src/run.sh":              {Data: []byte("#!/bin/sh\n"), Mode: 0o755},
+	"src/empty":               {Mode: 0o644},
+	".git/HEAD":               {Data: []byte("ref: refs/heads/main\n"), Mode: 0o644},
+	"web/node_modules/x/x.js": {Data: []byte("x"), Mode: 0o644},
+	"web/index.html":          {Data: []byte("<html></html>"), Mode: 0o644},
+	"link":                    {Data: []byte("README.md"), Mode: 0o777 | fs.ModeSymlink},
+
+	// Long enough to need a PAX header in tar.
+	longName: {Data: []byte("long"), Mode: 0o644},
+}
+
+var longName = strings.Repeat("very-long-directory-name/", 6) + "file.txt"
+
+// readArchive returns the regular files in an archive mapped to their
+// contents.
+func readArchive(t *testing.T, format archive.Format, data []byte) map[string]string {
+	files := make(map[string]string)
+
+	if format == archive.FormatZip {
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		require.NoError(t, err)
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			r, err := f.Open()
+			require.NoError(t, err)
+			content, err := io.ReadAll(r)
+			require.NoError(t, err)
+			files[f.Name] = string(content)
+		}
+		return files
+	}
+
+	var r io.Reader = bytes.NewReader(data)
+	switch format {
+	case archive.FormatTarZstd:
+		z, err := zstd.NewReader(r)
+		require.NoError(t, err)
+		defer z.Close()
+		r = z
+	case archive.FormatTarGzip:
+		z, err := gzip.NewReader(r)
+		require.NoError(t, err)
+		r = z
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		require.NoError(t, err)
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[hdr.Name] = string(content)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name    string
+		exclude []string
+		want    []string
+	}{
+		{
+			name: "All",
+			want: []string{".git/HEAD", "README.md", "src/empty", "src/main.go", "src/run.sh", longName, "web/index.html", "web/node_modules/x/x.js"},
+		},
+		{
+			name:    "Exclude",
+			exclude: []string{".git", "node_modules", "very-*"},
+			want:    []string{"README.md", "src/empty", "src/main.go", "src/run.sh", "web/index.html"},
+		},
+		{
+			name:    "ExcludePath",
+			exclude: []string{"src/*.sh", "web"},
+			want:    []string{".git/HEAD", "README.md", "src/empty", "src/main.go", longName},
+		},
+	}
+
+	for _, format := range archive.Formats {
+		for _, test := range tests {
+			t.Run(string(format)+"/"+test.name, func(t *testing.T) {
+				var buf bytes.Buffer
+				err := archive.Write(&buf, testFS, archive.Options{Format: format, Exclude: test.exclude})
+				require.NoError(t, err)
+
+				files := readArchive(t, format, buf.Bytes())
+				names := slices.Sorted(func(yield func(string) bool) {
+					for name := range files {
+						if !yield(name) {
+							return
+						}
+					}
+				})
+				require.Equal(t, test.want, names)
+				for _, name := range names {
+					require.Equal(t, string(testFS[name].Data), files[name], name)
+				}
+			})
+		}
+	}
+}
+
+func TestWriteExtract(t *testing.T) {
+	for _, format := range archive.Formats {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			err := archive.Write(&buf, testFS, archive.Options{Format: format})
+			require.NoError(t, err)
+
+			dst := t.TempDir()
+			stats, err := archive.Extract(&buf, format, dst, testLimits)
+			require.NoError(t, err)
+			require.Equal(t, 8, stats.Files)
+
+			data, err := os.ReadFile(filepath.Join(dst, "src", "main.go"))
+			require.NoError(t, err)
+			require.Equal(t, "package main\n", string(data))
+		})
+	}
+}
+
+func TestSize(t *testing.T) {
+	for _, exclude := range [][]string{nil, {".git", "node_modules"}} {
+		opts := archive.Options{Format: archive.FormatTar, Exclude: exclude}
+
+		size, err := archive.Size(testFS, opts)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		err = archive.Write(&buf, testFS, opts)
+		require.NoError(t, err)
+		require.Equal(t, int64(buf.Len()), size)
+	}
+
+	for _, format := range []archive.Format{archive.FormatTarZstd, archive.FormatTarGzip, archive.FormatZip} {
+		_, err := archive.Size(testFS, archive.Options{Format: format})
+		require.ErrorIs(t, err, archive.ErrSizeUnknown)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, format := range archive.Formats {
+		f, ok := archive.ParseFormat(string(format))
+		require.True(t, ok)
+		require.Equal(t, format, f)
+	}
+
+	f, ok := archive.ParseFormat("rar")
+	require.False(t, ok)
+	require.Equal(t, archive.FormatTarZstd, f)
+	require.Equal(t, ".tar.zst", archive.FormatTarZstd.Ext())
+	require.Equal(t, "photos.trayscale.tar.zst", archive.FormatTarZstd.FileName("photos"))
+}
@@ -3,7 +3,9 @@ package autosave_test
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"deedles.dev/trayscale/internal/autosave"
 	"github.com/stretchr/testify/require"
@@ -167,3 +169,92 @@ func TestUniquePath(t *testing.T) {
 	require.NoError(t, os.WriteFile(filepath.Join(dir, "note (1).txt"), []byte("b"), 0o644))
 	require.Equal(t, filepath.Join(dir, "note (2).txt"), autosave.UniquePath(dir, "subdir/note.txt"))
 }
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "report.pdf")
+	require.NoError(t, os.WriteFile(existing, []byte("old"), 0o644))
+	modTime := time.Date(2025, time.March, 7, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(existing, modTime, modTime))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "photos"), 0o755))
+
+	before, after := modTime.Add(-time.Hour), modTime.Add(time.Hour)
+	same := func(string) (bool, error) { return true, nil }
+	different := func(string) (bool, error) { return false, nil }
+	unused := func(string) (bool, error) {
+		t.Fatal("identical called unexpectedly")
+		return false, nil
+	}
+
+	tests := []struct {
+		name       string
+		policy     autosave.Policy
+		dest       string
+		received   time.Time
+		identical  func(string) (bool, error)
+		wantPath   string
+		wantAction autosave.Action
+	}{
+		{name: "no conflict", policy: autosave.PolicySkip, dest: "new.pdf", identical: unused, wantPath: "new.pdf", wantAction: autosave.ActionSave},
+		{name: "rename", policy: autosave.PolicyRename, dest: "report.pdf", identical: unused, wantPath: "report (1).pdf", wantAction: autosave.ActionSave},
+		{name: "overwrite", policy: autosave.PolicyOverwrite, dest: "report.pdf", identical: unused, wantPath: "report.pdf", wantAction: autosave.ActionSave},
+		{name: "overwrite directory", policy: autosave.PolicyOverwrite, dest: "photos", identical: unused, wantPath: "photos (1)", wantAction: autosave.ActionSave},
+		{name: "keep newest incoming", policy: autosave.PolicyKeepNewest, dest: "report.pdf", received: after, identical: unused, wantPath: "report.pdf", wantAction: autosave.ActionSave},
+		{name: "keep newest existing", policy: autosave.PolicyKeepNewest, dest: "report.pdf", received: before, identical: unused, wantAction: autosave.ActionSkip},
+		{name: "skip", policy: autosave.PolicySkip, dest: "report.pdf", identical: unused, wantAction: autosave.ActionSkip},
+		{name: "skip identical same", policy: autosave.PolicySkipIdentical, dest: "report.pdf", identical: same, wantAction: autosave.ActionDiscard},
+		{name: "skip identical different", policy: autosave.PolicySkipIdentical, dest: "report.pdf", identical: different, wantPath: "report (1).pdf", wantAction: autosave.ActionSave},
+		{name: "skip identical directory", policy: autosave.PolicySkipIdentical, dest: "photos", identical: unused, wantPath: "photos (1)", wantAction: autosave.ActionSave},
+		{name: "unknown policy", policy: "bogus", dest: "report.pdf", identical: unused, wantPath: "report (1).pdf", wantAction: autosave.ActionSave},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, action, err := autosave.Resolve(tt.policy, filepath.Join(dir, tt.dest), tt.received, tt.identical)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantAction, action)
+			if tt.wantPath != "" {
+				require.Equal(t, filepath.Join(dir, tt.wantPath), path)
+			}
+		})
+	}
+}
+
+func TestIdentical(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.bin")
+	require.NoError(t, os.WriteFile(path, []byte("contents"), 0o644))
+
+	tests := []struct {
+		name     string
+		incoming string
+		size     int64
+		want     bool
+	}{
+		{name: "same", incoming: "contents", size: 8, want: true},
+		{name: "different contents", incoming: "CONTENTS", size: 8, want: false},
+		{name: "different size", incoming: "content", size: 7, want: false},
+		{name: "short read", incoming: "content", size: 8, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := autosave.Identical(path, tt.size, strings.NewReader(tt.incoming))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := autosave.Identical(filepath.Join(dir, "missing"), 0, strings.NewReader(""))
+	require.Error(t, err)
+}
+
+func TestParsePolicy(t *testing.T) {
+	for _, policy := range autosave.Policies {
+		p, ok := autosave.ParsePolicy(string(policy))
+		require.True(t, ok)
+		require.Equal(t, policy, p)
+	}
+
+	p, ok := autosave.ParsePolicy("bogus")
+	require.False(t, ok)
+	require.Equal(t, autosave.PolicyRename, p)
+}
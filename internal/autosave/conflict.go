@@ -0,0 +1,177 @@
+package autosave
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Policy determines what happens when a file being saved would
+// replace an existing one.
+type Policy string
+
+const (
+	// PolicyRename saves the new file under a unique name. See
+	// UniqueName.
+	PolicyRename Policy = "rename"
+
+	// PolicyOverwrite replaces the existing file.
+	PolicyOverwrite Policy = "overwrite"
+
+	// PolicyKeepNewest replaces the existing file unless it was modified
+	// after the new file was received, in which case the new file is
+	// left waiting. Taildrop doesn't report when a file arrived, so the
+	// received time passed to Resolve is usually an approximation.
+	PolicyKeepNewest Policy = "keep-newest"
+
+	// PolicySkip leaves the new file waiting.
+	PolicySkip Policy = "skip"
+
+	// PolicySkipIdentical discards the new file if it has the same
+	// contents as the existing one and otherwise behaves like
+	// PolicyRename.
+	PolicySkipIdentical Policy = "skip-identical"
+)
+
+// Policies lists every conflict policy, default first.
+var Policies = []Policy{PolicyRename, PolicyOverwrite, PolicyKeepNewest, PolicySkip, PolicySkipIdentical}
+
+// ParsePolicy returns the Policy named by str. Unrecognized names
+// return PolicyRename and false.
+func ParsePolicy(str string) (Policy, bool) {
+	for _, p := range Policies {
+		if string(p) == str {
+			return p, true
+		}
+	}
+	return PolicyRename, false
+}
+
+// Action is what should be done with a waiting file after resolving a
+// conflict.
+type Action int
+
+const (
+	// ActionSave means that the file should be saved to the returned
+	// path.
+	ActionSave Action = iota
+
+	// ActionSkip means that the file should be left waiting.
+	ActionSkip
+
+	// ActionDiscard means that an identical copy of the file already
+	// exists and the waiting file can be deleted without saving it.
+	ActionDiscard
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionSave:
+		return "save"
+	case ActionSkip:
+		return "skip"
+	case ActionDiscard:
+		return "discard"
+	default:
+		return "unknown"
+	}
+}
+
+// Resolve decides how to save a waiting file to dest according to
+// policy. received is the time that the waiting file arrived, and
+// identical, which is only called for PolicySkipIdentical, reports
+// whether the existing file at the given path has the same contents as
+// the waiting file.
+//
+// If nothing exists at dest, the file is always saved there. Existing
+// directories are never replaced, so policies that would overwrite one
+// rename instead.
+func Resolve(policy Policy, dest string, received time.Time, identical func(path string) (bool, error)) (string, Action, error) {
+	info, err := os.Stat(dest)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return dest, ActionSave, nil
+		}
+		return "", ActionSkip, err
+	}
+
+	unique := func() string {
+		return UniquePath(filepath.Dir(dest), filepath.Base(dest))
+	}
+
+	switch policy {
+	case PolicyOverwrite:
+		if info.IsDir() {
+			return unique(), ActionSave, nil
+		}
+		return dest, ActionSave, nil
+
+	case PolicyKeepNewest:
+		if info.ModTime().After(received) {
+			return "", ActionSkip, nil
+		}
+		if info.IsDir() {
+			return unique(), ActionSave, nil
+		}
+		return dest, ActionSave, nil
+
+	case PolicySkip:
+		return "", ActionSkip, nil
+
+	case PolicySkipIdentical:
+		if info.Mode().IsRegular() {
+			same, err := identical(dest)
+			if err != nil {
+				return "", ActionSkip, err
+			}
+			if same {
+				return "", ActionDiscard, nil
+			}
+		}
+		return unique(), ActionSave, nil
+
+	default:
+		return unique(), ActionSave, nil
+	}
+}
+
+// Identical reports whether the file at path has exactly the contents
+// read from r, which are expected to be size bytes long. The sizes are
+// compared before any data is read.
+func Identical(path string, size int64, r io.Reader) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	if info.Size() != size {
+		return false, nil
+	}
+
+	existing := sha256.New()
+	_, err = io.Copy(existing, file)
+	if err != nil {
+		return false, err
+	}
+
+	incoming := sha256.New()
+	n, err := io.Copy(incoming, r)
+	if err != nil {
+		return false, err
+	}
+	if n != size {
+		return false, nil
+	}
+
+	return bytes.Equal(existing.Sum(nil), incoming.Sum(nil)), nil
+}
@@ -0,0 +1,95 @@
+package autosave
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/inhies/go-bytesize"
+)
+
+// Limits are safeguards that are checked before a file is saved
+// automatically. Zero values disable the corresponding check.
+type Limits struct {
+	// MaxSize is the largest file, in bytes, that may be auto-saved.
+	MaxSize int64
+
+	// MinFree is the amount of space, in bytes, that must remain free
+	// on the destination filesystem after the file is saved.
+	MinFree int64
+
+	// Confirm, if true, holds every file for manual confirmation.
+	// Tailscale doesn't report who sent a waiting file, so this takes
+	// the place of a list of senders whose files may be auto-saved.
+	Confirm bool
+}
+
+// Hold explains why a file was not auto-saved.
+type Hold int
+
+const (
+	HoldNone Hold = iota
+	HoldTooLarge
+	HoldLowDisk
+	HoldConfirm
+)
+
+func (h Hold) String() string {
+	switch h {
+	case HoldNone:
+		return "none"
+	case HoldTooLarge:
+		return "too large"
+	case HoldLowDisk:
+		return "low disk space"
+	case HoldConfirm:
+		return "confirmation required"
+	default:
+		return fmt.Sprintf("Hold(%d)", int(h))
+	}
+}
+
+// Check returns the reason that f should not be auto-saved to a
+// filesystem with free bytes available, or HoldNone if it may be. A
+// negative free means that the available space is unknown, in which
+// case the disk space check is skipped.
+func (l Limits) Check(f File, free int64) Hold {
+	if l.Confirm {
+		return HoldConfirm
+	}
+
+	if l.MaxSize > 0 && f.Size > l.MaxSize {
+		return HoldTooLarge
+	}
+
+	if l.MinFree > 0 && free >= 0 && free-f.Size < l.MinFree {
+		return HoldLowDisk
+	}
+
+	return HoldNone
+}
+
+// Describe returns a human-readable explanation of why f was held
+// because of h.
+func (l Limits) Describe(h Hold, f File) string {
+	switch h {
+	case HoldTooLarge:
+		return fmt.Sprintf("%v (%v) is larger than the auto-save limit of %v", f.Name, bytesize.ByteSize(f.Size), bytesize.ByteSize(l.MaxSize))
+	case HoldLowDisk:
+		return fmt.Sprintf("Not enough free space to auto-save %v (%v)", f.Name, bytesize.ByteSize(f.Size))
+	case HoldConfirm:
+		return fmt.Sprintf("%v (%v) is waiting to be saved", f.Name, bytesize.ByteSize(f.Size))
+	default:
+		return ""
+	}
+}
+
+// FreeSpace returns the number of bytes available to unprivileged
+// users on the filesystem containing dir.
+func FreeSpace(dir string) (int64, error) {
+	var stat syscall.Statfs_t
+	err := syscall.Statfs(dir, &stat)
+	if err != nil {
+		return 0, err
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
@@ -0,0 +1,45 @@
+package autosave_test
+
+import (
+	"testing"
+
+	"deedles.dev/trayscale/internal/autosave"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitsCheck(t *testing.T) {
+	small := autosave.File{Name: "small.txt", Size: 1 << 10}
+	large := autosave.File{Name: "large.iso", Size: 4 << 30}
+
+	tests := []struct {
+		name   string
+		limits autosave.Limits
+		file   autosave.File
+		free   int64
+		want   autosave.Hold
+	}{
+		{name: "no limits", limits: autosave.Limits{}, file: large, free: 0, want: autosave.HoldNone},
+		{name: "under max size", limits: autosave.Limits{MaxSize: 1 << 20}, file: small, free: -1, want: autosave.HoldNone},
+		{name: "over max size", limits: autosave.Limits{MaxSize: 1 << 20}, file: large, free: -1, want: autosave.HoldTooLarge},
+		{name: "max size inclusive", limits: autosave.Limits{MaxSize: 1 << 10}, file: small, free: -1, want: autosave.HoldNone},
+		{name: "enough space", limits: autosave.Limits{MinFree: 1 << 30}, file: small, free: 2 << 30, want: autosave.HoldNone},
+		{name: "low space", limits: autosave.Limits{MinFree: 1 << 30}, file: large, free: 4<<30 + 1<<29, want: autosave.HoldLowDisk},
+		{name: "space unknown", limits: autosave.Limits{MinFree: 1 << 30}, file: large, free: -1, want: autosave.HoldNone},
+		{name: "confirm", limits: autosave.Limits{Confirm: true}, file: small, free: -1, want: autosave.HoldConfirm},
+		{name: "confirm checked first", limits: autosave.Limits{Confirm: true, MaxSize: 1}, file: large, free: 0, want: autosave.HoldConfirm},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.limits.Check(tt.file, tt.free))
+		})
+	}
+}
+
+func TestFreeSpace(t *testing.T) {
+	free, err := autosave.FreeSpace(t.TempDir())
+	require.NoError(t, err)
+	require.Positive(t, free)
+
+	_, err = autosave.FreeSpace("/does/not/exist")
+	require.Error(t, err)
+}
@@ -0,0 +1,316 @@
+package autosave
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// File describes a waiting file for the purposes of routing it to a
+// destination.
+type File struct {
+	Name string
+	Size int64
+
+	// MIMEType is the MIME type of the file, such as "image/png". It is
+	// usually guessed from the file's extension by the caller and may be
+	// empty if it is unknown.
+	MIMEType string
+
+	// Sender is the host name or login name of the peer that sent the
+	// file. It is empty if the sender is unknown.
+	//
+	// The LocalAPI doesn't currently report who sent a waiting file, so
+	// the app always leaves this empty. Until it does, sender
+	// conditions never match and {sender} always expands to "unknown".
+	Sender string
+}
+
+// Rule routes waiting files that match all of its conditions to a
+// destination directory. Empty conditions always match.
+type Rule struct {
+	Name string `json:"name,omitempty"`
+
+	// Senders is a list of path.Match patterns matched
+	// case-insensitively against the file's sender.
+	Senders []string `json:"senders,omitempty"`
+
+	// Extensions is a list of file extensions, with or without the
+	// leading dot, matched case-insensitively.
+	Extensions []string `json:"extensions,omitempty"`
+
+	// MIMETypes is a list of path.Match patterns, such as "image/*",
+	// matched against the file's MIME type.
+	MIMETypes []string `json:"mime_types,omitempty"`
+
+	// MinSize and MaxSize are inclusive bounds on the file's size in
+	// bytes. Zero means no bound.
+	MinSize int64 `json:"min_size,omitempty"`
+	MaxSize int64 `json:"max_size,omitempty"`
+
+	// Dest is a template for the destination directory. See Expand.
+	Dest string `json:"dest"`
+}
+
+// Match reports whether f satisfies every condition of r.
+func (r Rule) Match(f File) bool {
+	if len(r.Senders) != 0 && !matchAny(r.Senders, strings.ToLower(f.Sender), strings.ToLower) {
+		return false
+	}
+
+	if len(r.Extensions) != 0 {
+		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(f.Name), "."))
+		if ext == "" || !matchAny(r.Extensions, ext, normalizeExt) {
+			return false
+		}
+	}
+
+	if len(r.MIMETypes) != 0 && !matchAny(r.MIMETypes, baseMIMEType(f.MIMEType), strings.ToLower) {
+		return false
+	}
+
+	if r.MinSize > 0 && f.Size < r.MinSize {
+		return false
+	}
+	if r.MaxSize > 0 && f.Size > r.MaxSize {
+		return false
+	}
+
+	return true
+}
+
+func matchAny(patterns []string, str string, normalize func(string) string) bool {
+	if str == "" {
+		return false
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(normalize(p), str); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeExt(ext string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+}
+
+// baseMIMEType strips parameters, such as "; charset=utf-8", from a
+// MIME type.
+func baseMIMEType(mimeType string) string {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	return strings.ToLower(strings.TrimSpace(mimeType))
+}
+
+// Route returns the first rule in rules that matches f.
+func Route(rules []Rule, f File) (Rule, bool) {
+	for _, r := range rules {
+		if r.Match(f) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Destination returns the directory that f should be saved into. If a
+// rule matches f, its expanded destination is returned. Otherwise, dir
+// is returned.
+func Destination(rules []Rule, dir string, f File, now time.Time, home string) (string, error) {
+	r, ok := Route(rules, f)
+	if !ok {
+		return dir, nil
+	}
+
+	dest, err := Expand(r.Dest, f, now, home)
+	if err != nil {
+		return "", fmt.Errorf("rule %q: %w", r.Name, err)
+	}
+	return dest, nil
+}
+
+// unknownSender is substituted for {sender} when the sender of a file
+// isn't known.
+const unknownSender = "unknown"
+
+// Expand expands the destination template tmpl for f. A leading "~" is
+// replaced with home, and the following placeholders are replaced:
+//
+//   - {sender}: the sender of the file, or "unknown"
+//   - {date}: the current date in YYYY-MM-DD format
+//   - {year}, {month}, {day}: the components of the current date
+//   - {ext}: the file's extension without the dot, or "none"
+//   - {type}: the top-level MIME type of the file, such as "image", or
+//     "unknown"
+//
+// Substituted values never contain path separators, so they can't
+// change the structure of the resulting path. The result must be an
+// absolute path.
+func Expand(tmpl string, f File, now time.Time, home string) (string, error) {
+	tmpl = strings.TrimSpace(tmpl)
+	if tmpl == "" {
+		return "", errors.New("empty destination")
+	}
+
+	if tmpl == "~" || strings.HasPrefix(tmpl, "~/") {
+		if home == "" {
+			return "", errors.New("home directory unknown")
+		}
+		tmpl = home + tmpl[1:]
+	}
+
+	var sb strings.Builder
+	for {
+		start := strings.IndexByte(tmpl, '{')
+		if start < 0 {
+			sb.WriteString(tmpl)
+			break
+		}
+		end := strings.IndexByte(tmpl[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated placeholder in %q", tmpl)
+		}
+		end += start
+
+		val, err := placeholder(tmpl[start+1:end], f, now)
+		if err != nil {
+			return "", err
+		}
+
+		sb.WriteString(tmpl[:start])
+		sb.WriteString(sanitize(val))
+		tmpl = tmpl[end+1:]
+	}
+
+	dest := filepath.Clean(sb.String())
+	if !filepath.IsAbs(dest) {
+		return "", fmt.Errorf("destination %q is not absolute", dest)
+	}
+	return dest, nil
+}
+
+func placeholder(name string, f File, now time.Time) (string, error) {
+	switch name {
+	case "sender":
+		if f.Sender == "" {
+			return unknownSender, nil
+		}
+		return f.Sender, nil
+	case "date":
+		return now.Format(time.DateOnly), nil
+	case "year":
+		return strconv.Itoa(now.Year()), nil
+	case "month":
+		return fmt.Sprintf("%02d", now.Month()), nil
+	case "day":
+		return fmt.Sprintf("%02d", now.Day()), nil
+	case "ext":
+		ext := strings.TrimPrefix(filepath.Ext(f.Name), ".")
+		if ext == "" {
+			return "none", nil
+		}
+		return strings.ToLower(ext), nil
+	case "type":
+		major, _, ok := strings.Cut(baseMIMEType(f.MIMEType), "/")
+		if !ok || major == "" {
+			return "unknown", nil
+		}
+		return major, nil
+	default:
+		return "", fmt.Errorf("unknown placeholder {%v}", name)
+	}
+}
+
+// sanitize makes val safe to use as a single path element.
+func sanitize(val string) string {
+	val = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, val)
+	if val == "" || val == "." || val == ".." {
+		return "_"
+	}
+	return val
+}
+
+// ParseRules decodes a list of rules from its JSON representation as
+// stored in settings. An empty string is an empty list.
+func ParseRules(data string) ([]Rule, error) {
+	if strings.TrimSpace(data) == "" {
+		return nil, nil
+	}
+
+	var rules []Rule
+	err := json.Unmarshal([]byte(data), &rules)
+	return rules, err
+}
+
+// FormatRules encodes rules in the format expected by ParseRules.
+func FormatRules(rules []Rule) string {
+	if len(rules) == 0 {
+		return "[]"
+	}
+
+	data, _ := json.Marshal(rules)
+	return string(data)
+}
+
+// ParseSize parses a human-readable size, such as "512", "10K", or
+// "1.5 GB", into a number of bytes. Units are powers of 1024. An empty
+// string is zero.
+func ParseSize(str string) (int64, error) {
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return 0, nil
+	}
+
+	num := strings.TrimRightFunc(str, unicode.IsLetter)
+	unit := strings.TrimSuffix(strings.ToUpper(str[len(num):]), "B")
+	num = strings.TrimSpace(num)
+
+	var mult float64
+	switch unit {
+	case "":
+		mult = 1
+	case "K", "KI":
+		mult = 1 << 10
+	case "M", "MI":
+		mult = 1 << 20
+	case "G", "GI":
+		mult = 1 << 30
+	case "T", "TI":
+		mult = 1 << 40
+	default:
+		return 0, fmt.Errorf("unknown size unit in %q", str)
+	}
+
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", str)
+	}
+	return int64(n * mult), nil
+}
+
+// FormatSize formats a size in bytes using the largest unit that
+// represents it exactly, such that ParseSize returns the same value.
+// Zero is formatted as an empty string.
+func FormatSize(size int64) string {
+	if size <= 0 {
+		return ""
+	}
+
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	i := 0
+	for i < len(units)-1 && size%1024 == 0 {
+		size /= 1024
+		i++
+	}
+	return fmt.Sprintf("%d %s", size, units[i])
+}
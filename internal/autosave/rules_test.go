@@ -0,0 +1,206 @@
+package autosave_test
+
+import (
+	"testing"
+	"time"
+
+	"deedles.dev/trayscale/internal/autosave"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleMatch(t *testing.T) {
+	screenshot := autosave.File{Name: "Screenshot.PNG", Size: 300 << 10, MIMEType: "image/png", Sender: "laptop"}
+	build := autosave.File{Name: "app.tar.gz", Size: 80 << 20, MIMEType: "application/gzip", Sender: "ci-runner"}
+	notes := autosave.File{Name: "notes", Size: 12, MIMEType: "text/plain; charset=utf-8"}
+
+	tests := []struct {
+		name string
+		rule autosave.Rule
+		file autosave.File
+		want bool
+	}{
+		{name: "empty", rule: autosave.Rule{}, file: notes, want: true},
+		{name: "extension", rule: autosave.Rule{Extensions: []string{".png", "jpg"}}, file: screenshot, want: true},
+		{name: "extension without dot", rule: autosave.Rule{Extensions: []string{"PNG"}}, file: screenshot, want: true},
+		{name: "extension mismatch", rule: autosave.Rule{Extensions: []string{"jpg"}}, file: screenshot, want: false},
+		{name: "no extension", rule: autosave.Rule{Extensions: []string{"*"}}, file: notes, want: false},
+		{name: "mime glob", rule: autosave.Rule{MIMETypes: []string{"image/*"}}, file: screenshot, want: true},
+		{name: "mime params", rule: autosave.Rule{MIMETypes: []string{"text/plain"}}, file: notes, want: true},
+		{name: "mime mismatch", rule: autosave.Rule{MIMETypes: []string{"image/*"}}, file: build, want: false},
+		{name: "mime unknown", rule: autosave.Rule{MIMETypes: []string{"*"}}, file: autosave.File{Name: "x"}, want: false},
+		{name: "sender", rule: autosave.Rule{Senders: []string{"CI-*"}}, file: build, want: true},
+		{name: "sender mismatch", rule: autosave.Rule{Senders: []string{"ci-*"}}, file: screenshot, want: false},
+		{name: "sender unknown", rule: autosave.Rule{Senders: []string{"*"}}, file: notes, want: false},
+		{name: "min size", rule: autosave.Rule{MinSize: 1 << 20}, file: build, want: true},
+		{name: "below min size", rule: autosave.Rule{MinSize: 1 << 20}, file: screenshot, want: false},
+		{name: "max size", rule: autosave.Rule{MaxSize: 1 << 20}, file: screenshot, want: true},
+		{name: "above max size", rule: autosave.Rule{MaxSize: 1 << 20}, file: build, want: false},
+		{name: "size bounds inclusive", rule: autosave.Rule{MinSize: 12, MaxSize: 12}, file: notes, want: true},
+		{
+			name: "all conditions",
+			rule: autosave.Rule{Senders: []string{"laptop"}, Extensions: []string{"png"}, MIMETypes: []string{"image/png"}, MaxSize: 1 << 20},
+			file: screenshot,
+			want: true,
+		},
+		{
+			name: "one condition fails",
+			rule: autosave.Rule{Senders: []string{"phone"}, Extensions: []string{"png"}},
+			file: screenshot,
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.rule.Match(tt.file))
+		})
+	}
+}
+
+func TestDestination(t *testing.T) {
+	now := time.Date(2025, time.March, 7, 15, 4, 5, 0, time.UTC)
+	rules := []autosave.Rule{
+		{Name: "Screenshots", MIMETypes: []string{"image/*"}, Dest: "~/Pictures/Screenshots"},
+		{Name: "Builds", Extensions: []string{"gz", "zst"}, MinSize: 1 << 20, Dest: "/scratch/{sender}/{date}"},
+		{Name: "Broken", Extensions: []string{"bad"}, Dest: "relative/{sender}"},
+	}
+
+	tests := []struct {
+		name    string
+		file    autosave.File
+		want    string
+		wantErr bool
+	}{
+		{name: "first rule", file: autosave.File{Name: "a.png", MIMEType: "image/png"}, want: "/home/user/Pictures/Screenshots"},
+		{name: "template", file: autosave.File{Name: "b.tar.gz", Size: 2 << 20, Sender: "ci"}, want: "/scratch/ci/2025-03-07"},
+		{name: "unknown sender", file: autosave.File{Name: "b.tar.zst", Size: 2 << 20}, want: "/scratch/unknown/2025-03-07"},
+		{name: "no match", file: autosave.File{Name: "b.tar.gz", Size: 10}, want: "/downloads"},
+		{name: "relative", file: autosave.File{Name: "x.bad"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := autosave.Destination(rules, "/downloads", tt.file, now, "/home/user")
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	now := time.Date(2025, time.March, 7, 15, 4, 5, 0, time.UTC)
+	file := autosave.File{Name: "Photo.JPG", MIMEType: "image/jpeg", Sender: "phone"}
+
+	tests := []struct {
+		name    string
+		tmpl    string
+		file    autosave.File
+		home    string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain", tmpl: "/srv/inbox", file: file, want: "/srv/inbox"},
+		{name: "home", tmpl: "~/Taildrop", file: file, home: "/home/user", want: "/home/user/Taildrop"},
+		{name: "home only", tmpl: "~", file: file, home: "/home/user", want: "/home/user"},
+		{name: "home unknown", tmpl: "~/Taildrop", file: file, wantErr: true},
+		{name: "not home", tmpl: "~other/x", file: file, home: "/home/user", wantErr: true},
+		{name: "all placeholders", tmpl: "/t/{sender}/{year}/{month}/{day}/{type}/{ext}", file: file, want: "/t/phone/2025/03/07/image/jpg"},
+		{name: "unknown values", tmpl: "/t/{sender}/{type}/{ext}", file: autosave.File{Name: "README"}, want: "/t/unknown/unknown/none"},
+		{name: "sanitized sender", tmpl: "/t/{sender}", file: autosave.File{Sender: "../../etc"}, want: "/t/.._.._etc"},
+		{name: "dot-dot sender", tmpl: "/t/{sender}/x", file: autosave.File{Sender: ".."}, want: "/t/_/x"},
+		{name: "unknown placeholder", tmpl: "/t/{nope}", file: file, wantErr: true},
+		{name: "unterminated", tmpl: "/t/{date", file: file, wantErr: true},
+		{name: "empty", tmpl: "  ", file: file, wantErr: true},
+		{name: "relative", tmpl: "{sender}", file: file, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := autosave.Expand(tt.tmpl, tt.file, now, tt.home)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules := []autosave.Rule{
+		{Name: "Screenshots", MIMETypes: []string{"image/*"}, Dest: "~/Pictures"},
+		{Senders: []string{"ci"}, MinSize: 10, MaxSize: 20, Dest: "/scratch"},
+	}
+
+	got, err := autosave.ParseRules(autosave.FormatRules(rules))
+	require.NoError(t, err)
+	require.Equal(t, rules, got)
+
+	got, err = autosave.ParseRules("")
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	got, err = autosave.ParseRules(autosave.FormatRules(nil))
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	_, err = autosave.ParseRules("{")
+	require.Error(t, err)
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "", want: 0},
+		{in: "512", want: 512},
+		{in: "512B", want: 512},
+		{in: "10K", want: 10 << 10},
+		{in: "10 KiB", want: 10 << 10},
+		{in: "1.5 GB", want: 3 << 29},
+		{in: "2m", want: 2 << 20},
+		{in: "1T", want: 1 << 40},
+		{in: "5 parsecs", wantErr: true},
+		{in: "-1", wantErr: true},
+		{in: "MB", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := autosave.ParseSize(tt.in)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{in: 0, want: ""},
+		{in: 512, want: "512 B"},
+		{in: 10 << 10, want: "10 KB"},
+		{in: 3 << 29, want: "1536 MB"},
+		{in: 1 << 40, want: "1 TB"},
+		{in: 1<<20 + 1, want: "1048577 B"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got := autosave.FormatSize(tt.in)
+			require.Equal(t, tt.want, got)
+
+			size, err := autosave.ParseSize(got)
+			require.NoError(t, err)
+			require.Equal(t, tt.in, size)
+		})
+	}
+}
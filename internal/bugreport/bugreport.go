@@ -0,0 +1,196 @@
+// Package bugreport generates archives containing information that is
+// useful for diagnosing problems so that they can be attached to bug
+// reports.
+package bugreport
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/netip"
+	"runtime"
+	"slices"
+	"time"
+
+	"deedles.dev/trayscale/internal/errreport"
+	"deedles.dev/trayscale/internal/logbuf"
+	"deedles.dev/trayscale/internal/netreport"
+	"deedles.dev/trayscale/internal/redact"
+)
+
+// Bundle is the information that is included in a bug report.
+type Bundle struct {
+	Time    time.Time
+	Version string
+
+	// Marker is the marker that tailscaled wrote to its logs when the
+	// bug report was generated. If it couldn't be generated, MarkerErr
+	// is the reason why.
+	Marker    string
+	MarkerErr error
+
+	// Status is the Tailscale status, if it is known.
+	Status *Status
+
+	// NetCheck is the most recent netcheck report, if there is one.
+	NetCheck        *netreport.Report
+	NetCheckHistory []netreport.Summary
+
+	// Preferences are the values of the app's settings by key. See
+	// redactPreference for how they are redacted.
+	Preferences map[string]any
+
+	Logs   []logbuf.Entry
+	Errors []errreport.Report
+}
+
+// FileName returns a name for a file that the bug report is saved to.
+func (b Bundle) FileName() string {
+	return "trayscale-bugreport-" + b.Time.Format("20060102-150405") + ".zip"
+}
+
+// Write writes the bug report to w as a zip archive. Any private
+// information that it contains is replaced by r. If r is nil, nothing
+// is replaced. Status is not redacted by Write, but it should have
+// been redacted by r before Write is called so that the same
+// placeholders are used throughout.
+func (b Bundle) Write(w io.Writer, r *redact.Redactor) error {
+	z := zip.NewWriter(w)
+
+	files := []struct {
+		name  string
+		write func(io.Writer) error
+		skip  bool
+	}{
+		{name: "README.txt", write: func(w io.Writer) error { return b.writeReadme(w, r) }},
+		{name: "status.json", write: func(w io.Writer) error { return writeJSON(w, b.Status) }, skip: b.Status == nil},
+		{name: "netcheck.json", write: func(w io.Writer) error { return writeJSON(w, redactNetCheck(*b.NetCheck, r)) }, skip: b.NetCheck == nil},
+		{name: "netcheck-history.json", write: func(w io.Writer) error { return writeJSON(w, b.NetCheckHistory) }, skip: len(b.NetCheckHistory) == 0},
+		{name: "preferences.json", write: func(w io.Writer) error { return b.writePreferences(w, r) }},
+		{name: "errors.txt", write: func(w io.Writer) error { return b.writeErrors(w, r) }},
+		{name: "logs.txt", write: func(w io.Writer) error { return b.writeLogs(w, r) }},
+	}
+	for _, file := range files {
+		if file.skip {
+			continue
+		}
+
+		fw, err := z.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: b.Time,
+		})
+		if err != nil {
+			return fmt.Errorf("create %v: %w", file.name, err)
+		}
+		err = file.write(fw)
+		if err != nil {
+			return fmt.Errorf("write %v: %w", file.name, err)
+		}
+	}
+
+	err := z.Close()
+	if err != nil {
+		return fmt.Errorf("close archive: %w", err)
+	}
+	return nil
+}
+
+func (b Bundle) writeReadme(w io.Writer, r *redact.Redactor) error {
+	marker := b.Marker
+	if b.MarkerErr != nil {
+		marker = r.Text("unavailable: " + b.MarkerErr.Error())
+	}
+
+	_, err := fmt.Fprintf(w, "Trayscale bug report\n\n"+
+		"Generated: %v\n"+
+		"Version: %v\n"+
+		"Go: %v %v/%v\n"+
+		"Redacted: %v\n"+
+		"Tailscale marker: %v\n",
+		b.Time.Format(time.RFC3339),
+		b.Version,
+		runtime.Version(), runtime.GOOS, runtime.GOARCH,
+		r != nil,
+		marker,
+	)
+	return err
+}
+
+func (b Bundle) writePreferences(w io.Writer, r *redact.Redactor) error {
+	// Keys are redacted in order so that the same settings always get
+	// the same placeholders.
+	prefs := make(map[string]any, len(b.Preferences))
+	for _, key := range slices.Sorted(maps.Keys(b.Preferences)) {
+		prefs[key] = redactPreference(key, b.Preferences[key], r)
+	}
+	return writeJSON(w, prefs)
+}
+
+func (b Bundle) writeErrors(w io.Writer, r *redact.Redactor) error {
+	for _, report := range b.Errors {
+		_, err := io.WriteString(w, r.Text(report.Details()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b Bundle) writeLogs(w io.Writer, r *redact.Redactor) error {
+	for _, e := range b.Logs {
+		_, err := fmt.Fprintln(w, r.Text(e.String()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, v any) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "\t")
+	return e.Encode(v)
+}
+
+// redactNetCheck returns a copy of report with the global addresses
+// that were found and the host names of the DERP servers replaced by
+// r. The DERP map is left out if r is not nil as it might contain the
+// addresses of private DERP servers.
+func redactNetCheck(report netreport.Report, r *redact.Redactor) netreport.Report {
+	if r == nil || report.Report == nil {
+		return report
+	}
+
+	report.DERPMap = nil
+	report.Report = report.Report.Clone()
+	report.Report.GlobalV4 = r.AddrPort(report.Report.GlobalV4)
+	report.Report.GlobalV6 = r.AddrPort(report.Report.GlobalV6)
+	report.Report.GlobalV4Counters = redactCounters(report.Report.GlobalV4Counters, r)
+	report.Report.GlobalV6Counters = redactCounters(report.Report.GlobalV6Counters, r)
+
+	report.Regions = slices.Clone(report.Regions)
+	for i, region := range report.Regions {
+		nodes := make([]string, 0, len(region.Nodes))
+		for _, node := range region.Nodes {
+			nodes = append(nodes, r.Name("derp", node))
+		}
+		report.Regions[i].Nodes = nodes
+	}
+
+	return report
+}
+
+func redactCounters(counters map[netip.AddrPort]int, r *redact.Redactor) map[netip.AddrPort]int {
+	if counters == nil {
+		return nil
+	}
+
+	redacted := make(map[netip.AddrPort]int, len(counters))
+	for _, addr := range slices.SortedFunc(maps.Keys(counters), netip.AddrPort.Compare) {
+		redacted[r.AddrPort(addr)] += counters[addr]
+	}
+	return redacted
+}
@@ -0,0 +1,146 @@
+package bugreport_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"maps"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"deedles.dev/trayscale/internal/bugreport"
+	"deedles.dev/trayscale/internal/errreport"
+	"deedles.dev/trayscale/internal/logbuf"
+	"deedles.dev/trayscale/internal/netreport"
+	"deedles.dev/trayscale/internal/redact"
+	"github.com/stretchr/testify/require"
+	"tailscale.com/net/netcheck"
+	"tailscale.com/tailcfg"
+)
+
+var testTime = time.Date(2026, 10, 19, 12, 30, 0, 0, time.UTC)
+
+func testBundle(r *redact.Redactor) bugreport.Bundle {
+	report := netreport.New(&netcheck.Report{
+		Now:      testTime,
+		UDP:      true,
+		GlobalV4: netip.MustParseAddrPort("203.0.113.40:41641"),
+		GlobalV4Counters: map[netip.AddrPort]int{
+			netip.MustParseAddrPort("203.0.113.40:41641"): 2,
+		},
+	}, &tailcfg.DERPMap{
+		Regions: map[int]*tailcfg.DERPRegion{
+			900: {RegionID: 900, RegionCode: "home", RegionName: "Home", Nodes: []*tailcfg.DERPNode{{HostName: "relay.home.arpa"}}},
+		},
+	})
+
+	return bugreport.Bundle{
+		Time:    testTime,
+		Version: "v1.2.3",
+		Marker:  "BUG-1234",
+		Status: &bugreport.Status{
+			State: "Running",
+			Self: &bugreport.Node{
+				Name:      r.DNSName("laptop.tail1234.ts.net."),
+				Addresses: []netip.Prefix{r.Prefix(netip.MustParsePrefix("100.101.102.103/32"))},
+			},
+			Peers: []bugreport.Node{},
+		},
+		NetCheck: &report,
+		Preferences: map[string]any{
+			"tray-icon":                true,
+			"taildrop-auto-save-dir":   "/home/user/Downloads",
+			"control-servers":          `[{"name":"Home","url":"https://headscale.home.arpa","admin_url":"https://admin.home.arpa/web"}]`,
+			"taildrop-auto-save-rules": `[{"dest":"/home/user/Photos/{date}","extensions":["jpg"]}]`,
+			"taildrop-post-save-hooks": []string{"curl -H 'Authorization: hunter2' https://hooks.home.arpa"},
+		},
+		Logs: []logbuf.Entry{
+			{Time: testTime, Level: slog.LevelInfo, Message: "connected", Attrs: "peer=laptop addr=100.101.102.103"},
+		},
+		Errors: []errreport.Report{
+			{Time: testTime, Message: "Failed to send file", Err: errors.New("dial 100.101.102.103: refused")},
+		},
+	}
+}
+
+func readZip(t *testing.T, data []byte) map[string]string {
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	files := make(map[string]string)
+	for _, f := range z.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+func TestFileName(t *testing.T) {
+	require.Equal(t, "trayscale-bugreport-20261019-123000.zip", bugreport.Bundle{Time: testTime}.FileName())
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, testBundle(nil).Write(&buf, nil))
+	files := readZip(t, buf.Bytes())
+
+	require.ElementsMatch(t, []string{
+		"README.txt",
+		"status.json",
+		"netcheck.json",
+		"preferences.json",
+		"errors.txt",
+		"logs.txt",
+	}, slices.Collect(maps.Keys(files)))
+	require.Contains(t, files["README.txt"], "Version: v1.2.3\n")
+	require.Contains(t, files["README.txt"], "Redacted: false\n")
+	require.Contains(t, files["README.txt"], "Tailscale marker: BUG-1234\n")
+	require.Contains(t, files["logs.txt"], "connected peer=laptop addr=100.101.102.103\n")
+	require.Contains(t, files["errors.txt"], "dial 100.101.102.103: refused")
+	require.Contains(t, files["netcheck.json"], "203.0.113.40:41641")
+	require.Contains(t, files["netcheck.json"], "relay.home.arpa")
+	require.Contains(t, files["preferences.json"], "headscale.home.arpa")
+	require.Contains(t, files["preferences.json"], "hunter2")
+
+	var status bugreport.Status
+	require.NoError(t, json.Unmarshal([]byte(files["status.json"]), &status))
+	require.Equal(t, "laptop.tail1234.ts.net.", status.Self.Name)
+}
+
+func TestWriteRedacted(t *testing.T) {
+	r := redact.New("/home/user")
+	bundle := testBundle(r)
+	bundle.Marker = ""
+	bundle.MarkerErr = errors.New("connect to 100.101.102.103: refused")
+
+	var buf bytes.Buffer
+	require.NoError(t, bundle.Write(&buf, r))
+	files := readZip(t, buf.Bytes())
+
+	for name, content := range files {
+		for _, private := range []string{"laptop", "tail1234", "100.101.102.103", "203.0.113.40", "/home/user", "home.arpa", "hunter2"} {
+			require.NotContains(t, content, private, name)
+		}
+	}
+
+	require.Contains(t, files["README.txt"], "Redacted: true\n")
+	require.Contains(t, files["README.txt"], "Tailscale marker: unavailable: connect to 100.64.0.1: refused\n")
+	require.Contains(t, files["logs.txt"], "connected peer=node1 addr=100.64.0.1\n")
+	require.Contains(t, files["netcheck.json"], "198.18.0.1:41641")
+	require.Contains(t, files["netcheck.json"], `"derp1"`)
+	require.Equal(t, []string{"relay.home.arpa"}, bundle.NetCheck.Regions[0].Nodes)
+	require.Contains(t, files["preferences.json"], "~/Downloads")
+	require.Contains(t, files["preferences.json"], "~/Photos/{date}")
+	require.Contains(t, files["preferences.json"], "controlplane1.example.com")
+	require.Contains(t, files["preferences.json"], `"hook1"`)
+	require.Contains(t, files["preferences.json"], `"tray-icon": true`)
+}
@@ -0,0 +1,75 @@
+package bugreport
+
+import (
+	"deedles.dev/trayscale/internal/autosave"
+	"deedles.dev/trayscale/internal/controlserver"
+	"deedles.dev/trayscale/internal/redact"
+)
+
+// redactPreference returns the value of the setting called key with
+// any private information in it replaced by r. Settings that are known
+// to contain URLs, paths, or commands are redacted according to their
+// structure. Other strings are redacted as free-form text.
+func redactPreference(key string, val any, r *redact.Redactor) any {
+	if r == nil {
+		return val
+	}
+
+	switch val := val.(type) {
+	case string:
+		switch key {
+		case "control-servers":
+			return redactControlServers(val, r)
+		case "taildrop-auto-save-rules":
+			return redactRules(val, r)
+		case "taildrop-auto-save-dir":
+			return r.Path(val)
+		default:
+			return r.Text(val)
+		}
+
+	case []string:
+		redacted := make([]string, 0, len(val))
+		for _, str := range val {
+			if key == "taildrop-post-save-hooks" {
+				// Hooks are arbitrary shell commands that might contain
+				// anything, including credentials.
+				redacted = append(redacted, r.Name("hook", str))
+				continue
+			}
+			redacted = append(redacted, r.Text(str))
+		}
+		return redacted
+
+	default:
+		return val
+	}
+}
+
+func redactControlServers(data string, r *redact.Redactor) any {
+	servers, err := controlserver.Parse(data)
+	if err != nil {
+		return nil
+	}
+
+	for i, server := range servers {
+		servers[i] = controlserver.Server{
+			Name:     r.Name("server", server.Name),
+			URL:      r.URL(server.URL),
+			AdminURL: r.URL(server.AdminURL),
+		}
+	}
+	return controlserver.Format(servers)
+}
+
+func redactRules(data string, r *redact.Redactor) any {
+	rules, err := autosave.ParseRules(data)
+	if err != nil {
+		return nil
+	}
+
+	for i := range rules {
+		rules[i].Dest = r.Text(r.Path(rules[i].Dest))
+	}
+	return autosave.FormatRules(rules)
+}
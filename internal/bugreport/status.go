@@ -0,0 +1,44 @@
+package bugreport
+
+import "net/netip"
+
+// Status is a snapshot of the Tailscale status that is included in a
+// bug report. Any private information in it should already have been
+// redacted.
+type Status struct {
+	State     string `json:"state"`
+	Tailnet   string `json:"tailnet,omitempty"`
+	LiveDERPs int    `json:"live_derps"`
+	LivePeers int    `json:"live_peers"`
+	Prefs     *Prefs `json:"prefs,omitempty"`
+	Self      *Node  `json:"self,omitempty"`
+	Peers     []Node `json:"peers"`
+}
+
+// Prefs are the preferences of the local node.
+type Prefs struct {
+	ControlURL             string         `json:"control_url"`
+	WantRunning            bool           `json:"want_running"`
+	RouteAll               bool           `json:"route_all"`
+	CorpDNS                bool           `json:"corp_dns"`
+	ShieldsUp              bool           `json:"shields_up"`
+	ExitNode               string         `json:"exit_node,omitempty"`
+	ExitNodeAllowLANAccess bool           `json:"exit_node_allow_lan_access"`
+	AdvertiseRoutes        []netip.Prefix `json:"advertise_routes,omitempty"`
+}
+
+// Node is a node in the tailnet.
+type Node struct {
+	Name           string         `json:"name"`
+	Hostname       string         `json:"hostname,omitempty"`
+	User           string         `json:"user,omitempty"`
+	OS             string         `json:"os,omitempty"`
+	Tags           []string       `json:"tags,omitempty"`
+	Addresses      []netip.Prefix `json:"addresses"`
+	PrimaryRoutes  []netip.Prefix `json:"primary_routes,omitempty"`
+	HomeDERP       int            `json:"home_derp,omitempty"`
+	Online         *bool          `json:"online,omitempty"`
+	ExitNode       bool           `json:"exit_node,omitempty"`
+	ExitNodeOption bool           `json:"exit_node_option,omitempty"`
+	Mullvad        bool           `json:"mullvad,omitempty"`
+}
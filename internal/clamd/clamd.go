@@ -0,0 +1,129 @@
+// Package clamd implements a minimal client for the ClamAV daemon's
+// INSTREAM command.
+package clamd
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// DefaultSocket is where clamd listens on most Linux distributions.
+const DefaultSocket = "/run/clamav/clamd.ctl"
+
+// chunkSize is the size of the chunks that data is streamed to clamd
+// in. It must be smaller than clamd's StreamMaxLength.
+const chunkSize = 64 * 1024
+
+// Result is the outcome of a successful scan.
+type Result struct {
+	// Infected is true if clamd found something.
+	Infected bool
+
+	// Signature is the name of what was found, such as
+	// "Eicar-Signature".
+	Signature string
+}
+
+// Client scans data using a clamd server.
+type Client struct {
+	// Network and Address are passed to net.Dial. If Network is empty,
+	// "unix" is used.
+	Network string
+	Address string
+
+	// Timeout limits how long clamd may take to respond after all of
+	// the data has been sent. Zero means no limit.
+	Timeout time.Duration
+}
+
+// Scan streams everything read from r to clamd and returns its
+// verdict.
+func (c Client) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	network := c.Network
+	if network == "" {
+		network = "unix"
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, c.Address)
+	if err != nil {
+		return Result{}, fmt.Errorf("connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	streamErr := stream(conn, r)
+	if streamErr != nil {
+		// clamd replies and hangs up early when, for example, the stream
+		// is too long, so try to get the reason.
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+	} else if c.Timeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(c.Timeout))
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil {
+		if streamErr != nil {
+			return Result{}, errors.Join(streamErr, ctx.Err())
+		}
+		return Result{}, errors.Join(fmt.Errorf("read clamd reply: %w", err), ctx.Err())
+	}
+
+	return parseReply(strings.TrimSuffix(reply, "\x00"))
+}
+
+func stream(w io.Writer, r io.Reader) error {
+	_, err := io.WriteString(w, "zINSTREAM\x00")
+	if err != nil {
+		return fmt.Errorf("send command: %w", err)
+	}
+
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, rerr := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			_, err := w.Write(buf[:4+n])
+			if err != nil {
+				return fmt.Errorf("send data: %w", err)
+			}
+		}
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		}
+		if rerr != nil {
+			return fmt.Errorf("read data: %w", rerr)
+		}
+	}
+
+	_, err = w.Write([]byte{0, 0, 0, 0})
+	if err != nil {
+		return fmt.Errorf("send data: %w", err)
+	}
+	return nil
+}
+
+func parseReply(reply string) (Result, error) {
+	_, status, ok := strings.Cut(reply, ": ")
+	if !ok {
+		status = reply
+	}
+
+	switch {
+	case status == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(status, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(status, " FOUND")}, nil
+	default:
+		return Result{}, fmt.Errorf("clamd: %v", strings.TrimSuffix(status, " ERROR"))
+	}
+}
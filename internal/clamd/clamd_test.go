@@ -0,0 +1,150 @@
+package clamd_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"deedles.dev/trayscale/internal/clamd"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClamd listens on a unix socket and answers INSTREAM requests.
+// Streams containing "EICAR" are reported as infected, and streams
+// larger than maxLen exceed the size limit.
+func fakeClamd(t *testing.T, maxLen int) (string, <-chan []byte) {
+	addr := filepath.Join(t.TempDir(), "clamd.sock")
+	l, err := net.Listen("unix", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	received := make(chan []byte, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				data, reply := handle(conn, maxLen)
+				received <- data
+				io.WriteString(conn, reply+"\x00")
+			}()
+		}
+	}()
+
+	return addr, received
+}
+
+func handle(conn net.Conn, maxLen int) ([]byte, string) {
+	r := bufio.NewReader(conn)
+	cmd, err := r.ReadString(0)
+	if err != nil || cmd != "zINSTREAM\x00" {
+		return nil, "UNKNOWN COMMAND"
+	}
+
+	var data bytes.Buffer
+	for {
+		var size uint32
+		err := binary.Read(r, binary.BigEndian, &size)
+		if err != nil {
+			return nil, "stream: read error ERROR"
+		}
+		if size == 0 {
+			break
+		}
+		if data.Len()+int(size) > maxLen {
+			return nil, "INSTREAM size limit exceeded. ERROR"
+		}
+		_, err = io.CopyN(&data, r, int64(size))
+		if err != nil {
+			return nil, "stream: read error ERROR"
+		}
+	}
+
+	if bytes.Contains(data.Bytes(), []byte("EICAR")) {
+		return data.Bytes(), "stream: Eicar-Signature FOUND"
+	}
+	return data.Bytes(), "stream: OK"
+}
+
+func TestScan(t *testing.T) {
+	addr, received := fakeClamd(t, 1<<20)
+	client := clamd.Client{Address: addr, Timeout: 5 * time.Second}
+
+	large := strings.Repeat("0123456789abcdef", 10000)
+	tests := []struct {
+		name    string
+		data    string
+		want    clamd.Result
+		wantErr bool
+	}{
+		{name: "clean", data: "hello, world", want: clamd.Result{}},
+		{name: "empty", data: "", want: clamd.Result{}},
+		{name: "multiple chunks", data: large, want: clamd.Result{}},
+		{name: "infected", data: "X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR", want: clamd.Result{Infected: true, Signature: "Eicar-Signature"}},
+		{name: "infected late", data: large + "EICAR", want: clamd.Result{Infected: true, Signature: "Eicar-Signature"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := client.Scan(context.Background(), strings.NewReader(tt.data))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+			require.Equal(t, tt.data, string(<-received))
+		})
+	}
+}
+
+func TestScanSizeLimit(t *testing.T) {
+	addr, _ := fakeClamd(t, 100)
+	client := clamd.Client{Address: addr}
+
+	_, err := client.Scan(context.Background(), strings.NewReader(strings.Repeat("x", 50)))
+	require.NoError(t, err)
+
+	_, err = client.Scan(context.Background(), strings.NewReader(strings.Repeat("x", 1000)))
+	require.ErrorContains(t, err, "size limit exceeded")
+
+	// Large enough that clamd hangs up in the middle of the stream.
+	_, err = client.Scan(context.Background(), strings.NewReader(strings.Repeat("x", 10<<20)))
+	require.ErrorContains(t, err, "size limit exceeded")
+}
+
+func TestScanUnavailable(t *testing.T) {
+	client := clamd.Client{Address: filepath.Join(t.TempDir(), "missing.sock")}
+	_, err := client.Scan(context.Background(), strings.NewReader("data"))
+	require.Error(t, err)
+}
+
+func TestScanCancel(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "clamd.sock")
+	l, err := net.Listen("unix", addr)
+	require.NoError(t, err)
+	defer l.Close()
+
+	// Accept connections but never reply.
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(io.Discard, conn)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	client := clamd.Client{Address: addr}
+	_, err = client.Scan(ctx, strings.NewReader("data"))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,86 @@
+// Package clipfile names and recognizes the files that Trayscale sends
+// with Taildrop when sharing the contents of the clipboard, so that
+// the receiving end can offer to put them back into a clipboard.
+package clipfile
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Kind is the kind of clipboard contents stored in a file.
+type Kind int
+
+const (
+	// Text is plain text, stored as a .txt file.
+	Text Kind = iota + 1
+
+	// Image is an image, stored as a .png file.
+	Image
+)
+
+// Ext returns the file extension, including the leading dot, used for
+// files of kind k.
+func (k Kind) Ext() string {
+	switch k {
+	case Text:
+		return ".txt"
+	case Image:
+		return ".png"
+	default:
+		return ""
+	}
+}
+
+const (
+	prefix     = "trayscale-clipboard-"
+	timeFormat = "20060102-150405"
+)
+
+// nameRE matches names produced by Name, including the " (n)" suffix
+// that Taildrop adds when a file of the same name is already waiting.
+var nameRE = regexp.MustCompile(`^` + regexp.QuoteMeta(prefix) + `\d{8}-\d{6}(?: \(\d+\))?(\.txt|\.png)$`)
+
+// Name returns the file name to use for clipboard contents of the
+// given kind sent at t.
+func Name(kind Kind, t time.Time) string {
+	return prefix + t.Format(timeFormat) + kind.Ext()
+}
+
+// Parse reports whether name is the name of a clipboard file, and if
+// so what kind of contents it holds.
+func Parse(name string) (Kind, bool) {
+	m := nameRE.FindStringSubmatch(name)
+	if m == nil {
+		return 0, false
+	}
+
+	switch m[1] {
+	case ".txt":
+		return Text, true
+	case ".png":
+		return Image, true
+	default:
+		return 0, false
+	}
+}
+
+// URL returns the URL contained in text if text, ignoring surrounding
+// whitespace, consists of a single absolute http or https URL.
+func URL(text string) (*url.URL, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" || strings.ContainsAny(text, " \t\r\n") {
+		return nil, false
+	}
+
+	u, err := url.Parse(text)
+	if err != nil {
+		return nil, false
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, false
+	}
+	return u, true
+}
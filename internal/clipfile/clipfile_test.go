@@ -0,0 +1,72 @@
+package clipfile_test
+
+import (
+	"testing"
+	"time"
+
+	"deedles.dev/trayscale/internal/clipfile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestName(t *testing.T) {
+	at := time.Date(2025, 3, 14, 15, 9, 26, 0, time.UTC)
+	require.Equal(t, "trayscale-clipboard-20250314-150926.txt", clipfile.Name(clipfile.Text, at))
+	require.Equal(t, "trayscale-clipboard-20250314-150926.png", clipfile.Name(clipfile.Image, at))
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		kind clipfile.Kind
+		ok   bool
+	}{
+		{name: "trayscale-clipboard-20250314-150926.txt", kind: clipfile.Text, ok: true},
+		{name: "trayscale-clipboard-20250314-150926.png", kind: clipfile.Image, ok: true},
+		{name: "trayscale-clipboard-20250314-150926 (2).txt", kind: clipfile.Text, ok: true},
+		{name: "trayscale-clipboard-20250314-150926.jpg"},
+		{name: "trayscale-clipboard-2025-150926.txt"},
+		{name: "notes.txt"},
+		{name: "xtrayscale-clipboard-20250314-150926.txt"},
+		{name: "trayscale-clipboard-20250314-150926.txt.exe"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, ok := clipfile.Parse(tt.name)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.kind, kind)
+		})
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	now := time.Now()
+	for _, kind := range []clipfile.Kind{clipfile.Text, clipfile.Image} {
+		got, ok := clipfile.Parse(clipfile.Name(kind, now))
+		require.True(t, ok)
+		require.Equal(t, kind, got)
+	}
+}
+
+func TestURL(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "https://example.com/path?q=1", want: "https://example.com/path?q=1"},
+		{text: "  http://example.com\n", want: "http://example.com"},
+		{text: "ftp://example.com"},
+		{text: "example.com"},
+		{text: "see https://example.com"},
+		{text: "https://"},
+		{text: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			u, ok := clipfile.URL(tt.text)
+			require.Equal(t, tt.want != "", ok)
+			if ok {
+				require.Equal(t, tt.want, u.String())
+			}
+		})
+	}
+}
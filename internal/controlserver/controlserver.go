@@ -0,0 +1,199 @@
+// Package controlserver manages the list of control servers that the
+// user has saved so that the daemon can easily be switched between
+// them.
+package controlserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"tailscale.com/ipn"
+	"tailscale.com/tailcfg"
+)
+
+// ErrInvalidURL is returned when a control server URL is not usable.
+var ErrInvalidURL = errors.New("invalid control server URL")
+
+// Server is a saved control server.
+type Server struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+
+	// AdminURL is the URL of a web-based admin console for the
+	// server, such as a Headscale UI. It is empty if there isn't one.
+	AdminURL string `json:"admin_url,omitempty"`
+}
+
+// Default is the control server run by Tailscale. It is always
+// available and is used when the daemon doesn't have a control URL
+// set.
+var Default = Server{
+	Name:     "Tailscale",
+	URL:      ipn.DefaultControlURL,
+	AdminURL: "https://login.tailscale.com/admin",
+}
+
+// tailscaleHosts are the hosts of control URLs that are run by
+// Tailscale and share its admin console.
+var tailscaleHosts = []string{
+	"controlplane.tailscale.com",
+	"login.tailscale.com",
+}
+
+// ValidateURL checks that raw is usable as a control server URL and
+// returns it in a normalized form. The scheme defaults to https if
+// raw doesn't have one.
+func ValidateURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", fmt.Errorf("%w: empty", ErrInvalidURL)
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidURL, err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+	default:
+		return "", fmt.Errorf("%w: unsupported scheme %q", ErrInvalidURL, u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("%w: no host", ErrInvalidURL)
+	}
+	if u.User != nil {
+		return "", fmt.Errorf("%w: must not contain credentials", ErrInvalidURL)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("%w: must not contain a query or fragment", ErrInvalidURL)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+	return u.String(), nil
+}
+
+// SameURL reports whether two control URLs refer to the same server.
+// An empty URL is the default server.
+func SameURL(u1, u2 string) bool {
+	normalize := func(u string) string {
+		if u == "" {
+			return Default.URL
+		}
+		if n, err := ValidateURL(u); err == nil {
+			return n
+		}
+		return u
+	}
+	return normalize(u1) == normalize(u2)
+}
+
+// Find returns the server in servers, or Default, with the given
+// control URL.
+func Find(servers []Server, url string) (Server, bool) {
+	if SameURL(url, Default.URL) {
+		return Default, true
+	}
+	for _, s := range servers {
+		if SameURL(url, s.URL) {
+			return s, true
+		}
+	}
+	return Server{}, false
+}
+
+// AdminURL returns the URL of the admin console for the control
+// server at controlURL. If the server is saved, its configured console
+// is used. Otherwise, a console is only known for servers run by
+// Tailscale.
+func AdminURL(servers []Server, controlURL string) (string, bool) {
+	if server, ok := Find(servers, controlURL); ok {
+		return server.AdminURL, server.AdminURL != ""
+	}
+
+	u, err := url.Parse(controlURL)
+	if err != nil {
+		return "", false
+	}
+	if slices.Contains(tailscaleHosts, strings.ToLower(u.Hostname())) {
+		return Default.AdminURL, true
+	}
+	return "", false
+}
+
+// Parse decodes a list of servers saved by Format.
+func Parse(data string) ([]Server, error) {
+	if strings.TrimSpace(data) == "" {
+		return nil, nil
+	}
+
+	var servers []Server
+	err := json.Unmarshal([]byte(data), &servers)
+	return servers, err
+}
+
+// Format encodes servers in the format expected by Parse.
+func Format(servers []Server) string {
+	if len(servers) == 0 {
+		return "[]"
+	}
+
+	data, _ := json.Marshal(servers)
+	return string(data)
+}
+
+// maxKeyResponse is the largest response to a key request that will
+// be read.
+const maxKeyResponse = 64 * 1024
+
+// Probe checks that a control server is reachable at url by
+// requesting its public key from the /key endpoint, which every
+// control server, including Headscale, implements. If client is nil,
+// http.DefaultClient is used.
+func Probe(ctx context.Context, client *http.Client, url string) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	keyURL := url + "/key?v=" + strconv.Itoa(int(tailcfg.CurrentCapabilityVersion))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, keyURL, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	rsp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response from %v: %v", keyURL, rsp.Status)
+	}
+
+	var keys struct {
+		PublicKey string `json:"publicKey"`
+	}
+	err = json.NewDecoder(io.LimitReader(rsp.Body, maxKeyResponse)).Decode(&keys)
+	if err != nil {
+		return fmt.Errorf("decode key response: %w", err)
+	}
+	if keys.PublicKey == "" {
+		return errors.New("control server did not return a public key")
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+// Package errreport keeps track of errors that have been shown to the
+// user so that their details can be looked at later.
+package errreport
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLimit is the number of reports kept by a Recent with no
+// limit set.
+const DefaultLimit = 50
+
+// Report is an error that was shown to the user.
+type Report struct {
+	Time    time.Time
+	Message string
+	Err     error
+}
+
+// Details returns a description of the report, including the full
+// chain of errors, suitable for copying into a bug report.
+func (r Report) Details() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%v: %v\n", r.Time.Format(time.RFC3339), r.Message)
+	for _, link := range Chain(r.Err) {
+		fmt.Fprintf(&sb, "  %v\n", link)
+	}
+	return sb.String()
+}
+
+// Chain returns the message of err followed by the messages of each
+// error that it wraps, in order. The errors wrapped by an error that
+// wraps more than one, such as one created by [errors.Join], are
+// indented beneath it.
+func Chain(err error) []string {
+	var chain []string
+	appendChain(&chain, err, "")
+	return chain
+}
+
+func appendChain(chain *[]string, err error, indent string) {
+	for err != nil {
+		*chain = append(*chain, indent+err.Error())
+
+		switch e := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, err := range e.Unwrap() {
+				appendChain(chain, err, indent+"  ")
+			}
+			return
+		default:
+			err = errors.Unwrap(err)
+		}
+	}
+}
+
+// Recent holds the most recent reports. It is safe for concurrent
+// use. A zero value is ready to use.
+type Recent struct {
+	// Limit is the number of reports to keep. If it is zero,
+	// DefaultLimit is used.
+	Limit int
+
+	m       sync.Mutex
+	reports []Report
+}
+
+// Add adds a report, dropping the oldest one if the limit has been
+// reached.
+func (r *Recent) Add(report Report) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	limit := r.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	r.reports = append(r.reports, report)
+	if len(r.reports) > limit {
+		r.reports = slices.Delete(r.reports, 0, len(r.reports)-limit)
+	}
+}
+
+// All returns the reports, newest first.
+func (r *Recent) All() []Report {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	reports := slices.Clone(r.reports)
+	slices.Reverse(reports)
+	return reports
+}
+
+// Len returns the number of reports.
+func (r *Recent) Len() int {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return len(r.reports)
+}
+
+// Clear removes all of the reports.
+func (r *Recent) Clear() {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.reports = nil
+}
@@ -0,0 +1,76 @@
+package errreport_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"deedles.dev/trayscale/internal/errreport"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChain(t *testing.T) {
+	base := errors.New("connection refused")
+
+	tests := []struct {
+		name  string
+		err   error
+		chain []string
+	}{
+		{name: "nil"},
+		{name: "single", err: base, chain: []string{"connection refused"}},
+		{
+			name: "wrapped",
+			err:  fmt.Errorf("get report: %w", fmt.Errorf("dial: %w", base)),
+			chain: []string{
+				"get report: dial: connection refused",
+				"dial: connection refused",
+				"connection refused",
+			},
+		},
+		{
+			name: "joined",
+			err:  fmt.Errorf("save: %w", errors.Join(base, fmt.Errorf("close: %w", errors.New("disk full")))),
+			chain: []string{
+				"save: connection refused\nclose: disk full",
+				"connection refused\nclose: disk full",
+				"  connection refused",
+				"  close: disk full",
+				"  disk full",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.chain, errreport.Chain(test.err))
+		})
+	}
+}
+
+func TestDetails(t *testing.T) {
+	r := errreport.Report{
+		Time:    time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+		Message: "Failed to delete file",
+		Err:     fmt.Errorf("delete: %w", errors.New("not found")),
+	}
+	details := r.Details()
+	require.True(t, strings.HasPrefix(details, "2026-10-19T12:00:00Z: Failed to delete file\n"))
+	require.Contains(t, details, "\n  not found\n")
+}
+
+func TestRecent(t *testing.T) {
+	recent := errreport.Recent{Limit: 3}
+	for i := range 5 {
+		recent.Add(errreport.Report{Message: fmt.Sprint(i)})
+	}
+	require.Equal(t, 3, recent.Len())
+
+	all := recent.All()
+	require.Equal(t, []string{"4", "3", "2"}, []string{all[0].Message, all[1].Message, all[2].Message})
+
+	recent.Clear()
+	require.Empty(t, recent.All())
+}
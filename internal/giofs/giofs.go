@@ -48,7 +48,10 @@ type file struct {
 func (file *file) init(fpath string) error {
 	dir := file.file.QueryFileType(file.ctx, 0) == gio.FileTypeDirectory
 	if dir {
-		children, err := file.file.EnumerateChildren(file.ctx, "*", 0)
+		// Symbolic links are reported as themselves rather than as their
+		// targets so that they can't be used to pull in files from
+		// outside of the root.
+		children, err := file.file.EnumerateChildren(file.ctx, "*", gio.FileQueryInfoNofollowSymlinks)
 		if err != nil {
 			return &fs.PathError{Op: "open", Path: fpath, Err: err}
 		}
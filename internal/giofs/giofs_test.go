@@ -0,0 +1,132 @@
+package giofs_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"deedles.dev/trayscale/internal/archive"
+	"deedles.dev/trayscale/internal/giofs"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/stretchr/testify/require"
+)
+
+var testLimits = archive.Limits{MaxBytes: 1 << 20, MaxFiles: 100}
+
+// makeTree creates a directory to archive and returns its path along
+// with the contents of the regular files that should end up in an
+// archive of it when .git and node_modules are excluded.
+func makeTree(t *testing.T) (string, map[string]string) {
+	outside := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o644))
+
+	root := filepath.Join(t.TempDir(), "project")
+	want := map[string]string{
+		"README.md":      "# Example\n",
+		"src/main.go":    "package main\n",
+		"src/empty":      "",
+		"web/index.html": "<html></html>",
+	}
+	files := map[string]string{
+		".git/HEAD":               "ref: refs/heads/main\n",
+		"web/node_modules/x/x.js": "x",
+	}
+	for name, data := range want {
+		files[name] = data
+	}
+	for name, data := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	}
+
+	require.NoError(t, os.Symlink("README.md", filepath.Join(root, "link")))
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "outside")))
+	require.NoError(t, os.Symlink("missing", filepath.Join(root, "dangling")))
+
+	return root, want
+}
+
+// readTree returns the regular files under dir mapped to their
+// contents.
+func readTree(t *testing.T, dir string) map[string]string {
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = string(data)
+		return err
+	})
+	require.NoError(t, err)
+	return files
+}
+
+func TestWrite(t *testing.T) {
+	root, want := makeTree(t)
+	fsys := giofs.New(context.Background(), gio.NewFileForPath(root))
+	opts := archive.Options{Exclude: []string{".git", "node_modules"}}
+
+	for _, format := range archive.Formats {
+		t.Run(string(format), func(t *testing.T) {
+			opts.Format = format
+
+			var buf bytes.Buffer
+			require.NoError(t, archive.Write(&buf, fsys, opts))
+
+			dst := t.TempDir()
+			_, err := archive.Extract(&buf, format, dst, testLimits)
+			require.NoError(t, err)
+			require.Equal(t, want, readTree(t, dst))
+		})
+	}
+}
+
+func TestReaderPreScan(t *testing.T) {
+	root, want := makeTree(t)
+	opts := giofs.Options{
+		Options: archive.Options{Format: archive.FormatTar, Exclude: []string{".git", "node_modules"}},
+		PreScan: true,
+	}
+
+	r, size, name, err := giofs.Reader(context.Background(), gio.NewFileForPath(root), opts)
+	require.NoError(t, err)
+	defer r.Close()
+	require.Equal(t, "project.trayscale.tar", name)
+
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), size)
+
+	dst := t.TempDir()
+	_, err = archive.Extract(bytes.NewReader(data), archive.FormatTar, dst, testLimits)
+	require.NoError(t, err)
+	require.Equal(t, want, readTree(t, dst))
+}
+
+func TestReaderCompressed(t *testing.T) {
+	root, _ := makeTree(t)
+	opts := giofs.Options{
+		Options: archive.Options{Format: archive.FormatTarZstd},
+		PreScan: true,
+	}
+
+	r, size, name, err := giofs.Reader(context.Background(), gio.NewFileForPath(root), opts)
+	require.NoError(t, err)
+	defer r.Close()
+	require.True(t, strings.HasSuffix(name, ".trayscale.tar.zst"), name)
+	require.Equal(t, int64(-1), size)
+
+	_, err = io.Copy(io.Discard, r)
+	require.NoError(t, err)
+}
@@ -1,20 +1,29 @@
 package giofs
 
 import (
-	"archive/tar"
 	"context"
 	"fmt"
 	"io"
 	"log/slog"
 
+	"deedles.dev/trayscale/internal/archive"
 	"github.com/diamondburned/gotk4/pkg/core/gioutil"
 	"github.com/diamondburned/gotk4/pkg/gio/v2"
-	"github.com/klauspost/compress/zstd"
 )
 
-func Reader(ctx context.Context, file gio.Filer) (io.ReadCloser, int64, string, error) {
+// Options configure how Reader handles directories.
+type Options struct {
+	archive.Options
+
+	// PreScan, if true, walks directories before archiving them in
+	// order to calculate the exact size of the archive. It only has an
+	// effect for formats that support it.
+	PreScan bool
+}
+
+func Reader(ctx context.Context, file gio.Filer, opts Options) (io.ReadCloser, int64, string, error) {
 	if file.QueryFileType(ctx, 0) == gio.FileTypeDirectory {
-		return dirReader(ctx, file)
+		return dirReader(ctx, file, opts)
 	}
 
 	info, err := file.QueryInfo(ctx, gio.FILE_ATTRIBUTE_STANDARD_SIZE, 0)
@@ -30,7 +39,19 @@ func Reader(ctx context.Context, file gio.Filer) (io.ReadCloser, int64, string,
 	return gioutil.Reader(ctx, s), info.Size(), file.Basename(), nil
 }
 
-func dirReader(ctx context.Context, file gio.Filer) (io.ReadCloser, int64, string, error) {
+func dirReader(ctx context.Context, file gio.Filer, opts Options) (io.ReadCloser, int64, string, error) {
+	size := int64(-1)
+	if opts.PreScan {
+		s, err := archive.Size(New(ctx, file), opts.Options)
+		switch err {
+		case nil:
+			size = s
+		case archive.ErrSizeUnknown:
+		default:
+			return nil, 0, "", fmt.Errorf("scan directory: %w", err)
+		}
+	}
+
 	r, w := io.Pipe()
 
 	go func() {
@@ -39,18 +60,13 @@ func dirReader(ctx context.Context, file gio.Filer) (io.ReadCloser, int64, strin
 
 		context.AfterFunc(ctx, func() { w.Close() })
 
-		z, _ := zstd.NewWriter(w)
-		defer z.Close()
-
-		w := tar.NewWriter(z)
-		defer w.Close()
-
 		root := New(ctx, file)
-		err := w.AddFS(root)
+		err := archive.Write(w, root, opts.Options)
 		if err != nil {
-			slog.Error("write tar file", "source", file.Path(), "err", err)
+			slog.Error("write archive", "source", file.Path(), "format", opts.Format, "err", err)
 		}
+		w.CloseWithError(err)
 	}()
 
-	return r, -1, file.Basename() + ".tar.zst", nil
+	return r, size, opts.Format.FileName(file.Basename()), nil
 }
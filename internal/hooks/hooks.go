@@ -0,0 +1,133 @@
+// Package hooks runs user-configured commands after Taildrop files
+// are saved.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// MaxOutput is the maximum amount of a hook's combined output that is
+// kept. Anything beyond it is discarded.
+const MaxOutput = 64 * 1024
+
+// File describes a saved file that hooks are run for.
+type File struct {
+	// Path is the absolute path that the file was saved to. For
+	// extracted archives, it is the path of the directory.
+	Path string
+
+	// Name is the name of the file as it was received.
+	Name string
+
+	// Sender is the sender of the file, or an empty string if it isn't
+	// known. The LocalAPI doesn't currently report who sent a waiting
+	// file, so the app always leaves it empty for now.
+	Sender string
+
+	// Size is the size of the received file in bytes.
+	Size int64
+
+	// Auto is true if the file was saved automatically.
+	Auto bool
+}
+
+// Env returns the environment variables that describe f to a hook.
+func (f File) Env() []string {
+	auto := "0"
+	if f.Auto {
+		auto = "1"
+	}
+
+	return []string{
+		"TRAYSCALE_FILE_PATH=" + f.Path,
+		"TRAYSCALE_FILE_NAME=" + f.Name,
+		"TRAYSCALE_FILE_SENDER=" + f.Sender,
+		"TRAYSCALE_FILE_SIZE=" + strconv.FormatInt(f.Size, 10),
+		"TRAYSCALE_FILE_AUTO=" + auto,
+	}
+}
+
+// Result is the outcome of running a hook.
+type Result struct {
+	Command  string
+	Output   []byte
+	Duration time.Duration
+
+	// Truncated is true if the output exceeded MaxOutput.
+	Truncated bool
+
+	// Err is non-nil if the hook could not be run, exited with a
+	// non-zero status, or timed out.
+	Err error
+}
+
+// ErrTimeout is returned in a Result when a hook runs for longer than
+// its timeout.
+var ErrTimeout = errors.New("hook timed out")
+
+// Run runs command with sh for f, killing it and everything that it
+// started if it takes longer than timeout. A timeout of zero or less
+// means no timeout. The hook's working directory is the directory
+// containing f.Path.
+func Run(ctx context.Context, command string, f File, timeout time.Duration) Result {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var output limitedBuffer
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(), f.Env()...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if f.Path != "" {
+		cmd.Dir = filepath.Dir(f.Path)
+	}
+
+	// Run the hook in its own process group so that anything it starts
+	// is killed along with it.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err := cmd.Run()
+	r := Result{
+		Command:   command,
+		Output:    output.buf.Bytes(),
+		Duration:  time.Since(start),
+		Truncated: output.truncated,
+		Err:       err,
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		r.Err = fmt.Errorf("%w after %v", ErrTimeout, timeout)
+	}
+	return r
+}
+
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(data []byte) (int, error) {
+	n := len(data)
+	if rem := MaxOutput - b.buf.Len(); len(data) > rem {
+		data = data[:rem]
+		b.truncated = true
+	}
+	b.buf.Write(data)
+	return n, nil
+}
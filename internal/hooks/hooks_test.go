@@ -0,0 +1,102 @@
+package hooks_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"deedles.dev/trayscale/internal/hooks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "photo.jpg")
+	require.NoError(t, os.WriteFile(path, []byte("jpeg"), 0o644))
+	file := hooks.File{Path: path, Name: "photo.jpg", Sender: "phone", Size: 4, Auto: true}
+
+	tests := []struct {
+		name    string
+		command string
+		timeout time.Duration
+		output  string
+		err     error
+		failed  bool
+	}{
+		{
+			name:    "env",
+			command: `echo "$TRAYSCALE_FILE_PATH|$TRAYSCALE_FILE_NAME|$TRAYSCALE_FILE_SENDER|$TRAYSCALE_FILE_SIZE|$TRAYSCALE_FILE_AUTO"`,
+			output:  path + "|photo.jpg|phone|4|1\n",
+		},
+		{
+			name:    "working directory",
+			command: `pwd -P`,
+			output:  evalSymlinks(t, dir) + "\n",
+		},
+		{
+			name:    "stderr",
+			command: `echo oops >&2; exit 3`,
+			output:  "oops\n",
+			failed:  true,
+		},
+		{
+			name:    "timeout",
+			command: `echo started; sleep 30`,
+			timeout: 100 * time.Millisecond,
+			output:  "started\n",
+			err:     hooks.ErrTimeout,
+		},
+		{
+			name:    "timeout kills children",
+			command: `sleep 30 & wait`,
+			timeout: 100 * time.Millisecond,
+			err:     hooks.ErrTimeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			r := hooks.Run(context.Background(), tt.command, file, tt.timeout)
+			require.Less(t, time.Since(start), 5*time.Second)
+
+			require.Equal(t, tt.command, r.Command)
+			require.Equal(t, tt.output, string(r.Output))
+			switch {
+			case tt.err != nil:
+				require.ErrorIs(t, r.Err, tt.err)
+			case tt.failed:
+				require.Error(t, r.Err)
+			default:
+				require.NoError(t, r.Err)
+			}
+		})
+	}
+}
+
+func TestRunTruncatesOutput(t *testing.T) {
+	r := hooks.Run(context.Background(), `head -c 100000 /dev/zero`, hooks.File{}, 0)
+	require.NoError(t, r.Err)
+	require.True(t, r.Truncated)
+	require.Len(t, r.Output, hooks.MaxOutput)
+}
+
+func TestFileEnv(t *testing.T) {
+	env := hooks.File{Path: "/tmp/a b.txt", Name: "a b.txt", Size: 12}.Env()
+	require.Contains(t, env, "TRAYSCALE_FILE_PATH=/tmp/a b.txt")
+	require.Contains(t, env, "TRAYSCALE_FILE_NAME=a b.txt")
+	require.Contains(t, env, "TRAYSCALE_FILE_SENDER=")
+	require.Contains(t, env, "TRAYSCALE_FILE_SIZE=12")
+	require.Contains(t, env, "TRAYSCALE_FILE_AUTO=0")
+	for _, v := range env {
+		require.True(t, strings.HasPrefix(v, "TRAYSCALE_FILE_"), v)
+	}
+}
+
+func evalSymlinks(t *testing.T, path string) string {
+	path, err := filepath.EvalSymlinks(path)
+	require.NoError(t, err)
+	return path
+}
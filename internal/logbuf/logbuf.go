@@ -0,0 +1,250 @@
+// Package logbuf keeps recent log records in memory so that they can
+// be shown to the user and included in bug reports.
+package logbuf
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLimit is the number of entries kept by a Buffer with no
+// limit set.
+const DefaultLimit = 5000
+
+// Entry is a single log record.
+type Entry struct {
+	Time    time.Time
+	Level   slog.Level
+	Message string
+
+	// Attrs holds the record's attributes formatted as space-separated
+	// key=value pairs.
+	Attrs string
+}
+
+// String formats the entry as a single line.
+func (e Entry) String() string {
+	var sb strings.Builder
+	sb.WriteString(e.Time.Format(time.RFC3339Nano))
+	sb.WriteByte(' ')
+	sb.WriteString(e.Level.String())
+	sb.WriteByte(' ')
+	sb.WriteString(e.Message)
+	if e.Attrs != "" {
+		sb.WriteByte(' ')
+		sb.WriteString(e.Attrs)
+	}
+	return sb.String()
+}
+
+// Matches reports whether the entry is at least as severe as level
+// and contains query, ignoring case, in its message or attributes.
+func (e Entry) Matches(level slog.Level, query string) bool {
+	if e.Level < level {
+		return false
+	}
+	if query == "" {
+		return true
+	}
+
+	query = strings.ToLower(query)
+	return strings.Contains(strings.ToLower(e.Message), query) ||
+		strings.Contains(strings.ToLower(e.Attrs), query)
+}
+
+// Buffer holds the most recent entries. It is safe for concurrent use.
+// A zero value is ready to use.
+type Buffer struct {
+	// Limit is the number of entries to keep. If it is zero,
+	// DefaultLimit is used. It must not be changed after the Buffer is
+	// first used.
+	Limit int
+
+	m        sync.Mutex
+	entries  []Entry
+	start    int
+	watchers map[int]func(Entry)
+	nextID   int
+}
+
+func (b *Buffer) limit() int {
+	if b.Limit <= 0 {
+		return DefaultLimit
+	}
+	return b.Limit
+}
+
+// Add adds an entry, replacing the oldest one if the buffer is full,
+// and then calls any watchers with it.
+func (b *Buffer) Add(e Entry) {
+	b.m.Lock()
+	if len(b.entries) < b.limit() {
+		b.entries = append(b.entries, e)
+	} else {
+		b.entries[b.start] = e
+		b.start = (b.start + 1) % len(b.entries)
+	}
+	watchers := slices.Collect(maps.Values(b.watchers))
+	b.m.Unlock()
+
+	for _, w := range watchers {
+		w(e)
+	}
+}
+
+// Entries returns the entries, oldest first.
+func (b *Buffer) Entries() []Entry {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	entries := make([]Entry, 0, len(b.entries))
+	entries = append(entries, b.entries[b.start:]...)
+	entries = append(entries, b.entries[:b.start]...)
+	return entries
+}
+
+// Clear removes all of the entries.
+func (b *Buffer) Clear() {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	b.entries = nil
+	b.start = 0
+}
+
+// Watch calls f with every entry that is added until the returned
+// function is called. f is called on whichever goroutine logged the
+// entry and must not log anything itself.
+func (b *Buffer) Watch(f func(Entry)) (stop func()) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	if b.watchers == nil {
+		b.watchers = make(map[int]func(Entry))
+	}
+	id := b.nextID
+	b.nextID++
+	b.watchers[id] = f
+
+	return func() {
+		b.m.Lock()
+		defer b.m.Unlock()
+
+		delete(b.watchers, id)
+	}
+}
+
+// Handler is a slog.Handler that adds every record that it handles to
+// a Buffer before passing it on to another handler. It only handles
+// records that the other handler is enabled for.
+type Handler struct {
+	buf    *Buffer
+	next   slog.Handler
+	attrs  string
+	prefix string
+}
+
+// NewHandler returns a handler that adds records to buf and then
+// passes them to next.
+func NewHandler(buf *Buffer, next slog.Handler) *Handler {
+	return &Handler{buf: buf, next: next}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	var sb strings.Builder
+	sb.WriteString(h.attrs)
+	r.Attrs(func(attr slog.Attr) bool {
+		appendAttr(&sb, h.prefix, attr)
+		return true
+	})
+
+	h.buf.Add(Entry{
+		Time:    r.Time,
+		Level:   r.Level,
+		Message: r.Message,
+		Attrs:   sb.String(),
+	})
+
+	return h.next.Handle(ctx, r)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var sb strings.Builder
+	sb.WriteString(h.attrs)
+	for _, attr := range attrs {
+		appendAttr(&sb, h.prefix, attr)
+	}
+
+	return &Handler{
+		buf:    h.buf,
+		next:   h.next.WithAttrs(attrs),
+		attrs:  sb.String(),
+		prefix: h.prefix,
+	}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	return &Handler{
+		buf:    h.buf,
+		next:   h.next.WithGroup(name),
+		attrs:  h.attrs,
+		prefix: h.prefix + name + ".",
+	}
+}
+
+func appendAttr(sb *strings.Builder, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, attr := range attr.Value.Group() {
+			appendAttr(sb, prefix, attr)
+		}
+		return
+	}
+
+	if sb.Len() > 0 {
+		sb.WriteByte(' ')
+	}
+	sb.WriteString(prefix)
+	sb.WriteString(attr.Key)
+	sb.WriteByte('=')
+	sb.WriteString(formatValue(attr.Value))
+}
+
+func formatValue(v slog.Value) string {
+	var str string
+	switch v.Kind() {
+	case slog.KindString:
+		str = v.String()
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	default:
+		str = fmt.Sprint(v.Any())
+	}
+
+	if str == "" || strings.ContainsFunc(str, func(c rune) bool { return c <= ' ' || c == '=' || c == '"' }) {
+		return strconv.Quote(str)
+	}
+	return str
+}
@@ -0,0 +1,82 @@
+package logbuf_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"deedles.dev/trayscale/internal/logbuf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer(t *testing.T) {
+	buf := logbuf.Buffer{Limit: 3}
+
+	var watched []string
+	stop := buf.Watch(func(e logbuf.Entry) { watched = append(watched, e.Message) })
+	for i := range 5 {
+		buf.Add(logbuf.Entry{Message: fmt.Sprint(i)})
+	}
+	stop()
+	buf.Add(logbuf.Entry{Message: "5"})
+
+	var messages []string
+	for _, e := range buf.Entries() {
+		messages = append(messages, e.Message)
+	}
+	require.Equal(t, []string{"3", "4", "5"}, messages)
+	require.Equal(t, []string{"0", "1", "2", "3", "4"}, watched)
+
+	buf.Clear()
+	require.Empty(t, buf.Entries())
+}
+
+func TestHandler(t *testing.T) {
+	var buf logbuf.Buffer
+	var out bytes.Buffer
+	logger := slog.New(logbuf.NewHandler(&buf, slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	logger.Debug("hidden")
+	logger.With("peer", "nodeA").WithGroup("file").Info("saved", "name", "a b.txt", "size", 3)
+	logger.Error("failed", "err", errors.New("no"), slog.Group("req", "id", 7))
+
+	entries := buf.Entries()
+	require.Len(t, entries, 2)
+	require.Equal(t, "saved", entries[0].Message)
+	require.Equal(t, slog.LevelInfo, entries[0].Level)
+	require.Equal(t, `peer=nodeA file.name="a b.txt" file.size=3`, entries[0].Attrs)
+	require.Equal(t, `err=no req.id=7`, entries[1].Attrs)
+	require.Contains(t, out.String(), "msg=failed")
+	require.NotContains(t, out.String(), "hidden")
+}
+
+func TestEntry(t *testing.T) {
+	e := logbuf.Entry{
+		Time:    time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+		Level:   slog.LevelWarn,
+		Message: "Infected file received",
+		Attrs:   "signature=Eicar",
+	}
+	require.Equal(t, "2026-10-19T12:00:00Z WARN Infected file received signature=Eicar", e.String())
+
+	tests := []struct {
+		name  string
+		level slog.Level
+		query string
+		match bool
+	}{
+		{name: "all", level: slog.LevelDebug, match: true},
+		{name: "too low", level: slog.LevelError},
+		{name: "message", level: slog.LevelInfo, query: "infected", match: true},
+		{name: "attrs", level: slog.LevelInfo, query: "EICAR", match: true},
+		{name: "missing", level: slog.LevelInfo, query: "clean"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.match, e.Matches(test.level, test.query))
+		})
+	}
+}
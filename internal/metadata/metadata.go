@@ -16,6 +16,10 @@ import (
 
 const AppID = "dev.deedles.Trayscale"
 
+// Private is true if private mode is enabled by setting
+// TRAYSCALE_PRIVATE=1. In private mode, information about the current
+// profile is hidden so that screenshots can be shared, and generated
+// bug reports are always redacted.
 var Private = os.Getenv("TRAYSCALE_PRIVATE") == "1"
 
 var version = ""
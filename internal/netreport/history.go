@@ -0,0 +1,251 @@
+package netreport
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"tailscale.com/types/opt"
+)
+
+// DefaultHistoryLimit is the number of summaries kept in the history
+// by default.
+const DefaultHistoryLimit = 100
+
+// Trigger is the reason that a netcheck was run.
+type Trigger string
+
+const (
+	TriggerManual     Trigger = "manual"
+	TriggerScheduled  Trigger = "scheduled"
+	TriggerLinkChange Trigger = "link-change"
+)
+
+// Name returns a short, human-readable name for the trigger.
+func (t Trigger) Name() string {
+	switch t {
+	case TriggerManual:
+		return "Manual"
+	case TriggerScheduled:
+		return "Scheduled"
+	case TriggerLinkChange:
+		return "Network Change"
+	default:
+		return "Unknown"
+	}
+}
+
+// Summary is the part of a netcheck report that is kept in the
+// history.
+//
+// Hairpinning is not included as netcheck no longer checks for it.
+type Summary struct {
+	Time    time.Time `json:"time"`
+	Trigger Trigger   `json:"trigger,omitempty"`
+
+	UDP  bool `json:"udp"`
+	IPv4 bool `json:"ipv4"`
+	IPv6 bool `json:"ipv6"`
+
+	MappingVariesByDestIP opt.Bool `json:"mapping_varies_by_dest_ip,omitempty"`
+	UPnP                  opt.Bool `json:"upnp,omitempty"`
+	PMP                   opt.Bool `json:"pmp,omitempty"`
+	PCP                   opt.Bool `json:"pcp,omitempty"`
+	CaptivePortal         opt.Bool `json:"captive_portal,omitempty"`
+
+	PreferredDERP        string        `json:"preferred_derp,omitempty"`
+	PreferredDERPLatency time.Duration `json:"preferred_derp_latency,omitempty"`
+}
+
+// Summary summarizes the report for the history.
+func (r Report) Summary(trigger Trigger) Summary {
+	s := Summary{
+		Time:    r.Time,
+		Trigger: trigger,
+	}
+	if r.Report != nil {
+		s.UDP = r.Report.UDP
+		s.IPv4 = r.Report.IPv4
+		s.IPv6 = r.Report.IPv6
+		s.MappingVariesByDestIP = r.Report.MappingVariesByDestIP
+		s.UPnP = r.Report.UPnP
+		s.PMP = r.Report.PMP
+		s.PCP = r.Report.PCP
+		s.CaptivePortal = r.Report.CaptivePortal
+	}
+	for _, region := range r.Regions {
+		if region.Home {
+			s.PreferredDERP = region.Name
+			s.PreferredDERPLatency = region.Latency
+			break
+		}
+	}
+	return s
+}
+
+// Field is a field of a Summary that is compared between netchecks.
+type Field int
+
+const (
+	FieldUDP Field = iota
+	FieldIPv4
+	FieldIPv6
+	FieldMapping
+	FieldUPnP
+	FieldPMP
+	FieldPCP
+	FieldCaptivePortal
+	FieldPreferredDERP
+)
+
+// Fields lists every Field in the order that they are usually shown.
+var Fields = []Field{
+	FieldUDP,
+	FieldIPv4,
+	FieldIPv6,
+	FieldMapping,
+	FieldUPnP,
+	FieldPMP,
+	FieldPCP,
+	FieldCaptivePortal,
+	FieldPreferredDERP,
+}
+
+// Name returns a short, human-readable name for the field.
+func (f Field) Name() string {
+	switch f {
+	case FieldUDP:
+		return "UDP"
+	case FieldIPv4:
+		return "IPv4"
+	case FieldIPv6:
+		return "IPv6"
+	case FieldMapping:
+		return "Varying NAT Mapping"
+	case FieldUPnP:
+		return "UPnP"
+	case FieldPMP:
+		return "NAT-PMP"
+	case FieldPCP:
+		return "PCP"
+	case FieldCaptivePortal:
+		return "Captive Portal"
+	case FieldPreferredDERP:
+		return "Preferred DERP"
+	default:
+		return "Unknown"
+	}
+}
+
+// Value returns the value of the field in s formatted for display.
+func (f Field) Value(s Summary) string {
+	switch f {
+	case FieldUDP:
+		return formatBool(s.UDP)
+	case FieldIPv4:
+		return formatBool(s.IPv4)
+	case FieldIPv6:
+		return formatBool(s.IPv6)
+	case FieldMapping:
+		return formatOptBool(s.MappingVariesByDestIP)
+	case FieldUPnP:
+		return formatOptBool(s.UPnP)
+	case FieldPMP:
+		return formatOptBool(s.PMP)
+	case FieldPCP:
+		return formatOptBool(s.PCP)
+	case FieldCaptivePortal:
+		return formatOptBool(s.CaptivePortal)
+	case FieldPreferredDERP:
+		return s.PreferredDERP
+	default:
+		return ""
+	}
+}
+
+func formatBool(v bool) string {
+	if v {
+		return "Yes"
+	}
+	return "No"
+}
+
+func formatOptBool(v opt.Bool) string {
+	b, ok := v.Get()
+	if !ok {
+		return "Unknown"
+	}
+	return formatBool(b)
+}
+
+// Changed returns the fields that differ between two summaries.
+func Changed(prev, cur Summary) []Field {
+	var changed []Field
+	for _, f := range Fields {
+		if f.Value(prev) != f.Value(cur) {
+			changed = append(changed, f)
+		}
+	}
+	return changed
+}
+
+// AppendHistory adds s to the end of history and then drops the
+// oldest entries so that there are at most limit of them.
+func AppendHistory(history []Summary, s Summary, limit int) []Summary {
+	history = append(history, s)
+	if limit > 0 && len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	return history
+}
+
+// LoadHistory loads a history saved by SaveHistory. If the file does
+// not exist, the history is empty.
+func LoadHistory(path string) ([]Summary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var history []Summary
+	err = json.Unmarshal(data, &history)
+	return history, err
+}
+
+// SaveHistory saves history to path, creating the directory that it
+// is in if necessary. The file is replaced atomically.
+func SaveHistory(path string, history []Summary) error {
+	data, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,91 @@
+package netreport_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"deedles.dev/trayscale/internal/netreport"
+	"github.com/stretchr/testify/require"
+	"tailscale.com/types/opt"
+)
+
+func TestSummary(t *testing.T) {
+	r := testReport()
+	r.UPnP = opt.NewBool(true)
+	s := netreport.New(r, testDERPMap()).Summary(netreport.TriggerScheduled)
+
+	require.Equal(t, netreport.TriggerScheduled, s.Trigger)
+	require.True(t, s.Time.Equal(r.Now))
+	require.True(t, s.UDP)
+	require.Equal(t, "San Francisco", s.PreferredDERP)
+	require.Equal(t, 10*time.Millisecond, s.PreferredDERPLatency)
+	require.Equal(t, "Yes", netreport.FieldUPnP.Value(s))
+	require.Equal(t, "Unknown", netreport.FieldPMP.Value(s))
+}
+
+func TestChanged(t *testing.T) {
+	prev := netreport.Summary{UDP: true, UPnP: opt.NewBool(true), PreferredDERP: "New York City"}
+
+	tests := []struct {
+		name    string
+		cur     netreport.Summary
+		changed []netreport.Field
+	}{
+		{name: "same", cur: prev},
+		{
+			name:    "udp blocked",
+			cur:     netreport.Summary{UPnP: opt.NewBool(true), PreferredDERP: "New York City"},
+			changed: []netreport.Field{netreport.FieldUDP},
+		},
+		{
+			name:    "nat and derp",
+			cur:     netreport.Summary{UDP: true, UPnP: opt.NewBool(false), PCP: opt.NewBool(true), PreferredDERP: "Frankfurt"},
+			changed: []netreport.Field{netreport.FieldUPnP, netreport.FieldPCP, netreport.FieldPreferredDERP},
+		},
+		{
+			name:    "only time differs",
+			cur:     netreport.Summary{Time: time.Now(), UDP: true, UPnP: opt.NewBool(true), PreferredDERP: "New York City"},
+			changed: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.changed, netreport.Changed(prev, tt.cur))
+		})
+	}
+}
+
+func TestAppendHistory(t *testing.T) {
+	var history []netreport.Summary
+	for i := range 5 {
+		history = netreport.AppendHistory(history, netreport.Summary{PreferredDERP: string(rune('a' + i))}, 3)
+	}
+	require.Len(t, history, 3)
+	require.Equal(t, "c", history[0].PreferredDERP)
+	require.Equal(t, "e", history[2].PreferredDERP)
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "history.json")
+
+	history, err := netreport.LoadHistory(path)
+	require.NoError(t, err)
+	require.Empty(t, history)
+
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	saved := []netreport.Summary{
+		{Time: now, Trigger: netreport.TriggerManual, UDP: true, UPnP: opt.NewBool(false), PreferredDERP: "Frankfurt", PreferredDERPLatency: time.Millisecond},
+		{Time: now.Add(time.Hour), Trigger: netreport.TriggerLinkChange},
+	}
+	require.NoError(t, netreport.SaveHistory(path, saved))
+
+	history, err = netreport.LoadHistory(path)
+	require.NoError(t, err)
+	require.Equal(t, saved, history)
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "temporary file left behind")
+}
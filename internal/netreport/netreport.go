@@ -0,0 +1,179 @@
+// Package netreport summarizes netcheck reports for display and
+// exports them for sharing.
+package netreport
+
+import (
+	"cmp"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"tailscale.com/net/netcheck"
+	"tailscale.com/tailcfg"
+)
+
+// Region is the result of a netcheck for a single DERP region.
+// Latencies are zero if the region wasn't reachable over that address
+// family.
+type Region struct {
+	ID        int           `json:"id"`
+	Code      string        `json:"code"`
+	Name      string        `json:"name"`
+	Latency   time.Duration `json:"latency,omitempty"`
+	LatencyV4 time.Duration `json:"latency_v4,omitempty"`
+	LatencyV6 time.Duration `json:"latency_v6,omitempty"`
+	Nodes     []string      `json:"nodes,omitempty"`
+	Home      bool          `json:"home,omitempty"`
+	Avoid     bool          `json:"avoid,omitempty"`
+}
+
+// Reachable reports whether the region responded during the netcheck.
+func (r Region) Reachable() bool {
+	return r.Latency > 0
+}
+
+// Regions returns every region in the DERP map along with the results
+// of the netcheck in r for it, sorted by latency.
+func Regions(r *netcheck.Report, dm *tailcfg.DERPMap) []Region {
+	if dm == nil {
+		return nil
+	}
+
+	regions := make([]Region, 0, len(dm.Regions))
+	for id, region := range dm.Regions {
+		if region == nil {
+			continue
+		}
+
+		nodes := make([]string, 0, len(region.Nodes))
+		for _, node := range region.Nodes {
+			nodes = append(nodes, node.HostName)
+		}
+
+		info := Region{
+			ID:    id,
+			Code:  region.RegionCode,
+			Name:  region.RegionName,
+			Nodes: nodes,
+			Avoid: region.Avoid,
+		}
+		if r != nil {
+			info.Latency = r.RegionLatency[id]
+			info.LatencyV4 = r.RegionV4Latency[id]
+			info.LatencyV6 = r.RegionV6Latency[id]
+			info.Home = id == r.PreferredDERP
+		}
+		regions = append(regions, info)
+	}
+
+	SortRegions(regions, SortLatency)
+	return regions
+}
+
+// SortBy is a key that regions can be sorted by.
+type SortBy int
+
+const (
+	SortLatency SortBy = iota
+	SortLatencyV4
+	SortLatencyV6
+	SortName
+	SortCode
+	SortID
+	SortHome
+)
+
+// CompareRegions compares two regions by the given key. Unreachable
+// regions sort after reachable ones when sorting by latency, the home
+// region sorts first when sorting by SortHome, and ties
+// are broken by name and then ID so that the order is stable.
+func CompareRegions(r1, r2 Region, by SortBy) int {
+	var c int
+	switch by {
+	case SortLatency:
+		c = compareLatency(r1.Latency, r2.Latency)
+	case SortLatencyV4:
+		c = compareLatency(r1.LatencyV4, r2.LatencyV4)
+	case SortLatencyV6:
+		c = compareLatency(r1.LatencyV6, r2.LatencyV6)
+	case SortCode:
+		c = cmp.Compare(r1.Code, r2.Code)
+	case SortID:
+		c = cmp.Compare(r1.ID, r2.ID)
+	case SortHome:
+		c = compareBool(r2.Home, r1.Home)
+	}
+
+	return cmp.Or(
+		c,
+		strings.Compare(strings.ToLower(r1.Name), strings.ToLower(r2.Name)),
+		cmp.Compare(r1.ID, r2.ID),
+	)
+}
+
+func compareLatency(l1, l2 time.Duration) int {
+	switch {
+	case l1 == l2:
+		return 0
+	case l1 == 0:
+		return 1
+	case l2 == 0:
+		return -1
+	default:
+		return cmp.Compare(l1, l2)
+	}
+}
+
+func compareBool(b1, b2 bool) int {
+	switch {
+	case b1 == b2:
+		return 0
+	case b1:
+		return 1
+	default:
+		return -1
+	}
+}
+
+// SortRegions sorts regions in place by the given key.
+func SortRegions(regions []Region, by SortBy) {
+	slices.SortFunc(regions, func(r1, r2 Region) int {
+		return CompareRegions(r1, r2, by)
+	})
+}
+
+// Report is a netcheck report along with the DERP regions that it was
+// run against. It is intended to be exported as JSON, for example to
+// attach to a support ticket.
+type Report struct {
+	Time    time.Time        `json:"time"`
+	Report  *netcheck.Report `json:"report"`
+	Regions []Region         `json:"regions"`
+	DERPMap *tailcfg.DERPMap `json:"derp_map,omitempty"`
+}
+
+// New returns a Report for the results of a netcheck.
+func New(r *netcheck.Report, dm *tailcfg.DERPMap) Report {
+	t := time.Now()
+	if r != nil && !r.Now.IsZero() {
+		t = r.Now
+	}
+
+	return Report{
+		Time:    t,
+		Report:  r,
+		Regions: Regions(r, dm),
+		DERPMap: dm,
+	}
+}
+
+// JSON returns the report as indented JSON.
+func (r Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "\t")
+}
+
+// FileName returns a name for a file that the report is saved to.
+func (r Report) FileName() string {
+	return "netcheck-" + r.Time.Format("20060102-150405") + ".json"
+}
@@ -0,0 +1,103 @@
+package netreport_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"deedles.dev/trayscale/internal/netreport"
+	"github.com/stretchr/testify/require"
+	"tailscale.com/net/netcheck"
+	"tailscale.com/tailcfg"
+)
+
+func testDERPMap() *tailcfg.DERPMap {
+	return &tailcfg.DERPMap{
+		Regions: map[int]*tailcfg.DERPRegion{
+			1: {RegionID: 1, RegionCode: "nyc", RegionName: "New York City", Nodes: []*tailcfg.DERPNode{{HostName: "derp1.example.com"}, {HostName: "derp1b.example.com"}}},
+			2: {RegionID: 2, RegionCode: "sfo", RegionName: "San Francisco", Nodes: []*tailcfg.DERPNode{{HostName: "derp2.example.com"}}},
+			3: {RegionID: 3, RegionCode: "fra", RegionName: "Frankfurt", Nodes: []*tailcfg.DERPNode{{HostName: "derp3.example.com"}}},
+			4: {RegionID: 4, RegionCode: "syd", RegionName: "Sydney", Avoid: true},
+		},
+	}
+}
+
+func testReport() *netcheck.Report {
+	return &netcheck.Report{
+		Now:             time.Date(2026, 10, 19, 12, 30, 0, 0, time.UTC),
+		UDP:             true,
+		PreferredDERP:   2,
+		RegionLatency:   map[int]time.Duration{1: 40 * time.Millisecond, 2: 10 * time.Millisecond, 3: 90 * time.Millisecond},
+		RegionV4Latency: map[int]time.Duration{1: 40 * time.Millisecond, 2: 10 * time.Millisecond, 3: 90 * time.Millisecond},
+		RegionV6Latency: map[int]time.Duration{1: 35 * time.Millisecond},
+	}
+}
+
+func ids(regions []netreport.Region) []int {
+	ids := make([]int, 0, len(regions))
+	for _, r := range regions {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func TestRegions(t *testing.T) {
+	regions := netreport.Regions(testReport(), testDERPMap())
+	require.Equal(t, []int{2, 1, 3, 4}, ids(regions))
+
+	require.True(t, regions[0].Home)
+	require.False(t, regions[1].Home)
+	require.Equal(t, "sfo", regions[0].Code)
+	require.Equal(t, []string{"derp1.example.com", "derp1b.example.com"}, regions[1].Nodes)
+	require.Equal(t, 35*time.Millisecond, regions[1].LatencyV6)
+	require.False(t, regions[3].Reachable())
+	require.True(t, regions[3].Avoid)
+
+	require.Nil(t, netreport.Regions(testReport(), nil))
+	require.Len(t, netreport.Regions(nil, testDERPMap()), 4)
+}
+
+func TestSortRegions(t *testing.T) {
+	tests := []struct {
+		name string
+		by   netreport.SortBy
+		ids  []int
+	}{
+		{name: "latency", by: netreport.SortLatency, ids: []int{2, 1, 3, 4}},
+		{name: "v6 latency", by: netreport.SortLatencyV6, ids: []int{1, 3, 2, 4}},
+		{name: "name", by: netreport.SortName, ids: []int{3, 1, 2, 4}},
+		{name: "code", by: netreport.SortCode, ids: []int{3, 1, 2, 4}},
+		{name: "id", by: netreport.SortID, ids: []int{1, 2, 3, 4}},
+		{name: "home", by: netreport.SortHome, ids: []int{2, 3, 1, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			regions := netreport.Regions(testReport(), testDERPMap())
+			netreport.SortRegions(regions, tt.by)
+			require.Equal(t, tt.ids, ids(regions))
+		})
+	}
+}
+
+func TestReportJSON(t *testing.T) {
+	report := netreport.New(testReport(), testDERPMap())
+	require.Equal(t, "netcheck-20261019-123000.json", report.FileName())
+
+	data, err := report.JSON()
+	require.NoError(t, err)
+
+	var decoded struct {
+		Time    time.Time
+		Report  struct{ PreferredDERP int }
+		Regions []struct {
+			Code string
+			Home bool
+		}
+	}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.True(t, decoded.Time.Equal(testReport().Now))
+	require.Equal(t, 2, decoded.Report.PreferredDERP)
+	require.Len(t, decoded.Regions, 4)
+	require.Equal(t, "sfo", decoded.Regions[0].Code)
+	require.True(t, decoded.Regions[0].Home)
+}
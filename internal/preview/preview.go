@@ -0,0 +1,292 @@
+// Package preview generates thumbnails and text snippets for waiting
+// Taildrop files so that they can be inspected before being saved.
+package preview
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/image/draw"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// MaxImageSize is the largest image file that will be decoded to
+	// generate a thumbnail.
+	MaxImageSize = 16 * 1024 * 1024
+
+	// MaxImagePixels is the largest number of pixels that an image may
+	// have to be decoded. This guards against small files that claim to
+	// be enormous images.
+	MaxImagePixels = 64 * 1024 * 1024
+
+	// MaxText is the number of bytes read from text files.
+	MaxText = 4 * 1024
+
+	// ThumbnailSize is the length of the longest side of thumbnails.
+	ThumbnailSize = 96
+
+	// sniffLen is the number of bytes used to detect a file's type.
+	sniffLen = 512
+)
+
+var (
+	// ErrTooLarge is returned when a file is too large to preview.
+	ErrTooLarge = errors.New("file too large to preview")
+
+	// ErrNotImage is returned by Image when a file isn't an image in a
+	// supported format.
+	ErrNotImage = errors.New("not a supported image")
+)
+
+// Kind is the type of preview that was generated for a file.
+type Kind int
+
+const (
+	KindNone Kind = iota
+	KindImage
+	KindText
+)
+
+// Preview is a preview of a file.
+type Preview struct {
+	Kind Kind
+
+	// ContentType is the detected MIME type of the file.
+	ContentType string
+
+	// Thumbnail is a PNG-encoded thumbnail for image files.
+	Thumbnail []byte
+
+	// Width and Height are the dimensions of the original image.
+	Width, Height int
+
+	// Text is the beginning of text files, with control characters
+	// removed.
+	Text string
+
+	// Truncated is true if Text is not the whole file.
+	Truncated bool
+}
+
+// Snippet returns a single line summarizing the text of the preview,
+// limited to n runes.
+func (p Preview) Snippet(n int) string {
+	if n < 1 {
+		return ""
+	}
+
+	line := strings.TrimSpace(p.Text)
+	line, _, cut := strings.Cut(line, "\n")
+	line = strings.TrimSpace(line)
+
+	if utf8.RuneCountInString(line) > n {
+		runes := []rune(line)
+		return string(runes[:n-1]) + "…"
+	}
+	if cut || p.Truncated {
+		return line + " …"
+	}
+	return line
+}
+
+// Generate reads the beginning of a file of the given size from r and
+// generates a preview of it. Files that aren't recognized as images or
+// text produce a preview of KindNone.
+func Generate(r io.Reader, size int64) (Preview, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return Preview{}, err
+	}
+
+	p := Preview{ContentType: http.DetectContentType(head)}
+	switch {
+	case strings.HasPrefix(p.ContentType, "image/"):
+		if size > MaxImageSize {
+			return p, ErrTooLarge
+		}
+		return p, generateImage(&p, br)
+
+	case strings.HasPrefix(p.ContentType, "text/"):
+		return p, generateText(&p, br, size)
+
+	default:
+		return p, nil
+	}
+}
+
+func generateImage(p *Preview, r io.Reader) error {
+	img, config, err := decode(r)
+	if err != nil || img == nil {
+		return err
+	}
+
+	thumb, err := Thumbnail(img, ThumbnailSize)
+	if err != nil {
+		return err
+	}
+
+	p.Kind = KindImage
+	p.Thumbnail = thumb
+	p.Width, p.Height = config.Width, config.Height
+	return nil
+}
+
+// decode decodes an image from r after checking that its dimensions
+// are reasonable. If r doesn't contain an image in a known format, it
+// returns a nil image and no error.
+func decode(r io.Reader) (image.Image, image.Config, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxImageSize))
+	if err != nil {
+		return nil, image.Config{}, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, config, nil
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxImagePixels {
+		return nil, config, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, config, nil
+	}
+	return img, config, nil
+}
+
+// Image decodes the image file of the given size from r and returns
+// it scaled to fit within size pixels as a PNG. It is used to show a
+// larger version of an image than its thumbnail.
+func Image(r io.Reader, size int64, dim int) ([]byte, error) {
+	if size > MaxImageSize {
+		return nil, ErrTooLarge
+	}
+
+	img, _, err := decode(r)
+	if err != nil {
+		return nil, err
+	}
+	if img == nil {
+		return nil, ErrNotImage
+	}
+	return Thumbnail(img, dim)
+}
+
+// Thumbnail scales img so that its longest side is size pixels long,
+// keeping its aspect ratio, and returns it encoded as a PNG. Images
+// that are already small enough are not scaled up.
+func Thumbnail(img image.Image, size int) ([]byte, error) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, max(1, h*size/w)
+		} else {
+			w, h = max(1, w*size/h), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, dst)
+	return buf.Bytes(), err
+}
+
+func generateText(p *Preview, r io.Reader, size int64) error {
+	data, err := io.ReadAll(io.LimitReader(r, MaxText))
+	if err != nil {
+		return err
+	}
+
+	// Don't show a partial character at the end.
+	for i := 0; i < utf8.UTFMax && len(data) > 0; i++ {
+		if r, _ := utf8.DecodeLastRune(data); r != utf8.RuneError {
+			break
+		}
+		data = data[:len(data)-1]
+	}
+
+	p.Kind = KindText
+	p.Text = clean(string(data))
+	p.Truncated = int64(len(data)) < size
+	return nil
+}
+
+// clean removes control characters other than newlines and tabs, and
+// normalizes line endings.
+func clean(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if r == utf8.RuneError || unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// Key identifies a waiting file in a Cache. A file that is replaced
+// by a new one with the same name will usually differ in size.
+type Key struct {
+	Name string
+	Size int64
+}
+
+// Cache stores previews. It is safe for concurrent use. The zero value
+// is ready to use.
+type Cache struct {
+	m       sync.Mutex
+	entries map[Key]Preview
+}
+
+// Get returns the cached preview for key, if there is one.
+func (c *Cache) Get(key Key) (Preview, bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	p, ok := c.entries[key]
+	return p, ok
+}
+
+// Put stores p as the preview for key.
+func (c *Cache) Put(key Key, p Preview) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[Key]Preview)
+	}
+	c.entries[key] = p
+}
+
+// Prune removes every entry for which keep returns false.
+func (c *Cache) Prune(keep func(Key) bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	for key := range c.entries {
+		if !keep(key) {
+			delete(c.entries, key)
+		}
+	}
+}
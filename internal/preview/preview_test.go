@@ -0,0 +1,182 @@
+package preview_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"deedles.dev/trayscale/internal/preview"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeImage(t *testing.T, w, h int, enc func(*bytes.Buffer, image.Image) error) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0x80, 0xFF})
+		}
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, enc(&buf, img))
+	return buf.Bytes()
+}
+
+func encodePNG(buf *bytes.Buffer, img image.Image) error { return png.Encode(buf, img) }
+
+func encodeJPEG(buf *bytes.Buffer, img image.Image) error { return jpeg.Encode(buf, img, nil) }
+
+func TestGenerateImage(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []byte
+		width     int
+		height    int
+		thumbW    int
+		thumbH    int
+		mediaType string
+	}{
+		{name: "png landscape", data: encodeImage(t, 400, 200, encodePNG), width: 400, height: 200, thumbW: preview.ThumbnailSize, thumbH: preview.ThumbnailSize / 2, mediaType: "image/png"},
+		{name: "jpeg portrait", data: encodeImage(t, 150, 300, encodeJPEG), width: 150, height: 300, thumbW: preview.ThumbnailSize / 2, thumbH: preview.ThumbnailSize, mediaType: "image/jpeg"},
+		{name: "small", data: encodeImage(t, 10, 20, encodePNG), width: 10, height: 20, thumbW: 10, thumbH: 20, mediaType: "image/png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := preview.Generate(bytes.NewReader(tt.data), int64(len(tt.data)))
+			require.NoError(t, err)
+			require.Equal(t, preview.KindImage, p.Kind)
+			require.Equal(t, tt.mediaType, p.ContentType)
+			require.Equal(t, tt.width, p.Width)
+			require.Equal(t, tt.height, p.Height)
+
+			thumb, err := png.Decode(bytes.NewReader(p.Thumbnail))
+			require.NoError(t, err)
+			require.Equal(t, tt.thumbW, thumb.Bounds().Dx())
+			require.Equal(t, tt.thumbH, thumb.Bounds().Dy())
+		})
+	}
+}
+
+func TestGenerateImageLimits(t *testing.T) {
+	_, err := preview.Generate(bytes.NewReader(encodeImage(t, 10, 10, encodePNG)), preview.MaxImageSize+1)
+	require.ErrorIs(t, err, preview.ErrTooLarge)
+
+	// A valid PNG header that claims to be enormous.
+	data := encodeImage(t, 1, 1, encodePNG)
+	data[16], data[17], data[18], data[19] = 0, 0x01, 0, 0 // width = 65536
+	data[20], data[21], data[22], data[23] = 0, 0x01, 0, 0 // height = 65536
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	_, err = preview.Generate(bytes.NewReader(data), int64(len(data)))
+	require.ErrorIs(t, err, preview.ErrTooLarge)
+
+	// Sniffs as an image but isn't one.
+	data = append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 100)...)
+	p, err := preview.Generate(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.Equal(t, preview.KindNone, p.Kind)
+}
+
+func TestImage(t *testing.T) {
+	data := encodeImage(t, 1000, 500, encodePNG)
+	out, err := preview.Image(bytes.NewReader(data), int64(len(data)), 600)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(out))
+	require.NoError(t, err)
+	require.Equal(t, 600, img.Bounds().Dx())
+	require.Equal(t, 300, img.Bounds().Dy())
+
+	_, err = preview.Image(strings.NewReader("not an image"), 12, 600)
+	require.ErrorIs(t, err, preview.ErrNotImage)
+}
+
+func TestGenerateText(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		text      string
+		truncated bool
+		snippet   string
+	}{
+		{name: "short", data: "hello, world\n", text: "hello, world\n", snippet: "hello, world"},
+		{name: "lines", data: "first\r\nsecond\n", text: "first\nsecond\n", snippet: "first …"},
+		{name: "control characters", data: "be\x7fep\x1b[0m\tok", text: "beep[0m\tok", snippet: "beep[0m\tok"},
+		{name: "long line", data: strings.Repeat("a", 100), text: strings.Repeat("a", 100), snippet: strings.Repeat("a", 19) + "…"},
+		{
+			name:      "truncated",
+			data:      strings.Repeat("é", preview.MaxText),
+			text:      strings.Repeat("é", preview.MaxText/2),
+			truncated: true,
+			snippet:   strings.Repeat("é", 19) + "…",
+		},
+		{
+			name:      "partial rune",
+			data:      "x" + strings.Repeat("é", preview.MaxText),
+			text:      "x" + strings.Repeat("é", preview.MaxText/2-1),
+			truncated: true,
+			snippet:   "x" + strings.Repeat("é", 18) + "…",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := preview.Generate(strings.NewReader(tt.data), int64(len(tt.data)))
+			require.NoError(t, err)
+			require.Equal(t, preview.KindText, p.Kind)
+			require.Equal(t, tt.text, p.Text)
+			require.Equal(t, tt.truncated, p.Truncated)
+			require.Equal(t, tt.snippet, p.Snippet(20))
+		})
+	}
+}
+
+func TestGenerateOther(t *testing.T) {
+	data := []byte("PK\x03\x04 pretend this is a zip file")
+	p, err := preview.Generate(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.Equal(t, preview.KindNone, p.Kind)
+	require.Equal(t, "application/zip", p.ContentType)
+
+	p, err = preview.Generate(bytes.NewReader(nil), 0)
+	require.NoError(t, err)
+	require.Equal(t, preview.KindText, p.Kind)
+	require.Empty(t, p.Text)
+}
+
+func TestSnippetShort(t *testing.T) {
+	p := preview.Preview{Kind: preview.KindText, Text: "hello"}
+	require.Empty(t, p.Snippet(0))
+	require.Empty(t, p.Snippet(-1))
+	require.Equal(t, "…", p.Snippet(1))
+}
+
+func TestCache(t *testing.T) {
+	var c preview.Cache
+
+	a := preview.Key{Name: "a.png", Size: 10}
+	b := preview.Key{Name: "b.txt", Size: 20}
+
+	_, ok := c.Get(a)
+	require.False(t, ok)
+
+	c.Put(a, preview.Preview{Kind: preview.KindImage})
+	c.Put(b, preview.Preview{Kind: preview.KindText})
+
+	p, ok := c.Get(a)
+	require.True(t, ok)
+	require.Equal(t, preview.KindImage, p.Kind)
+
+	// Same name, different size.
+	_, ok = c.Get(preview.Key{Name: "a.png", Size: 11})
+	require.False(t, ok)
+
+	c.Prune(func(k preview.Key) bool { return k == b })
+	_, ok = c.Get(a)
+	require.False(t, ok)
+	_, ok = c.Get(b)
+	require.True(t, ok)
+}
@@ -0,0 +1,299 @@
+// Package redact replaces private information, such as names and
+// addresses, with placeholders. It is used by the private mode set by
+// [metadata.Private] and when generating bug reports.
+//
+// [metadata.Private]: deedles.dev/trayscale/internal/metadata.Private
+package redact
+
+import (
+	"cmp"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Placeholders that are shown in place of the current profile in
+// private mode.
+const (
+	ProfileName = "profile@example.com"
+	Tailnet     = "example.com"
+	ControlURL  = "https://controlplane.example.com"
+)
+
+var (
+	tailscaleV4 = netip.MustParsePrefix("100.64.0.0/10")
+	tailscaleV6 = netip.MustParsePrefix("fd7a:115c:a1e0::/48")
+
+	// Replacements for addresses outside of the Tailscale ranges are
+	// taken from the ranges reserved for benchmarking and for
+	// documentation so that they can't be mistaken for real ones.
+	otherV4 = netip.MustParsePrefix("198.18.0.0/15")
+	otherV6 = netip.MustParsePrefix("2001:db8::/32")
+
+	addrRE = regexp.MustCompile(`[0-9A-Fa-f]*:[0-9A-Fa-f:.]*[0-9A-Fa-f]|\b\d{1,3}(?:\.\d{1,3}){3}\b`)
+)
+
+// publicHosts are the hosts of control servers that are not private.
+var publicHosts = []string{
+	"controlplane.tailscale.com",
+	"login.tailscale.com",
+}
+
+// Redactor replaces private information with placeholders. It always
+// replaces the same value with the same placeholder so that redacted
+// values can still be told apart. A nil *Redactor doesn't redact
+// anything.
+//
+// A Redactor is not safe for concurrent use.
+type Redactor struct {
+	home   string
+	names  map[string]string
+	addrs  map[netip.Addr]netip.Addr
+	counts map[string]int
+}
+
+// New returns a Redactor that also replaces home, the user's home
+// directory, with ~. If home is empty, it is ignored.
+func New(home string) *Redactor {
+	return &Redactor{
+		home:   strings.TrimSuffix(home, "/"),
+		names:  make(map[string]string),
+		addrs:  make(map[netip.Addr]netip.Addr),
+		counts: make(map[string]int),
+	}
+}
+
+func (r *Redactor) next(kind string) int {
+	r.counts[kind]++
+	return r.counts[kind]
+}
+
+func (r *Redactor) name(kind, s string, format func(n int) string) string {
+	if r == nil || s == "" {
+		return s
+	}
+
+	if p, ok := r.names[s]; ok {
+		return p
+	}
+	p := format(r.next(kind))
+	r.names[s] = p
+	return p
+}
+
+// Name returns a placeholder for s, such as a hostname, of the given
+// kind. Placeholders are numbered separately for each kind.
+func (r *Redactor) Name(kind, s string) string {
+	return r.name(kind, s, func(n int) string { return fmt.Sprintf("%v%v", kind, n) })
+}
+
+// Domain returns a placeholder for a domain name, such as that of a
+// tailnet. A trailing dot is kept.
+func (r *Redactor) Domain(s string) string {
+	trimmed := strings.TrimSuffix(s, ".")
+	p := r.name("domain", trimmed, func(n int) string { return fmt.Sprintf("tailnet%v.example.com", n) })
+	if trimmed != s {
+		p += "."
+	}
+	return p
+}
+
+// Email returns a placeholder for an email address, such as the login
+// name of a user.
+func (r *Redactor) Email(s string) string {
+	return r.name("email", s, func(n int) string { return fmt.Sprintf("user%v@example.com", n) })
+}
+
+// DNSName returns a placeholder for the MagicDNS name of a node. The
+// tailnet part of the name is replaced the same way as by Domain.
+func (r *Redactor) DNSName(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+
+	host, domain, ok := strings.Cut(s, ".")
+	if !ok || domain == "" {
+		return r.Name("node", host) + strings.TrimPrefix(s, host)
+	}
+	return r.Name("node", host) + "." + r.Domain(domain)
+}
+
+// URL returns the URL of a control server with its host replaced,
+// unless the host belongs to Tailscale.
+func (r *Redactor) URL(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return ControlURL
+	}
+	if slices.Contains(publicHosts, u.Hostname()) {
+		return s
+	}
+	return r.name("url", u.Scheme+"://"+u.Host, func(n int) string {
+		return fmt.Sprintf("https://controlplane%v.example.com", n)
+	})
+}
+
+// Addr returns a placeholder for addr. Addresses in the Tailscale
+// ranges are replaced by other addresses in the same ranges. Loopback,
+// unspecified, and link-local addresses are not replaced.
+func (r *Redactor) Addr(addr netip.Addr) netip.Addr {
+	if r == nil || !addr.IsValid() {
+		return addr
+	}
+	if addr.IsLoopback() || addr.IsUnspecified() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() {
+		return addr
+	}
+
+	addr = addr.Unmap()
+	if p, ok := r.addrs[addr]; ok {
+		return p
+	}
+
+	var p netip.Addr
+	switch {
+	case tailscaleV4.Contains(addr):
+		p = offset(tailscaleV4.Addr(), r.next("tailscale4"))
+	case tailscaleV6.Contains(addr):
+		p = offset(tailscaleV6.Addr(), r.next("tailscale6"))
+	case addr.Is4():
+		p = offset(otherV4.Addr(), r.next("other4"))
+	default:
+		p = offset(otherV6.Addr(), r.next("other6"))
+	}
+	r.addrs[addr] = p
+	return p
+}
+
+// offset returns the address n addresses after base.
+func offset(base netip.Addr, n int) netip.Addr {
+	b := base.AsSlice()
+	for i := len(b) - 1; (i >= 0) && (n > 0); i-- {
+		sum := int(b[i]) + (n & 0xFF)
+		b[i] = byte(sum)
+		n = (n >> 8) + (sum >> 8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// AddrPort returns addrPort with its address replaced the same way as
+// by Addr. The port is kept.
+func (r *Redactor) AddrPort(addrPort netip.AddrPort) netip.AddrPort {
+	if r == nil || !addrPort.IsValid() {
+		return addrPort
+	}
+	return netip.AddrPortFrom(r.Addr(addrPort.Addr()), addrPort.Port())
+}
+
+// Prefix returns prefix with its address replaced the same way as by
+// Addr. Prefixes that cover more than a single address, such as
+// advertised subnets, are replaced by placeholders of the same size.
+func (r *Redactor) Prefix(prefix netip.Prefix) netip.Prefix {
+	if r == nil || !prefix.IsValid() {
+		return prefix
+	}
+	if prefix.Bits() == 0 {
+		// Default routes, such as those advertised by exit nodes, are
+		// not private.
+		return prefix
+	}
+	return netip.PrefixFrom(r.Addr(prefix.Addr()), prefix.Bits()).Masked()
+}
+
+// Path returns path with the user's home directory replaced by ~.
+func (r *Redactor) Path(path string) string {
+	if r == nil || r.home == "" {
+		return path
+	}
+	if path == r.home {
+		return "~"
+	}
+	if rest, ok := strings.CutPrefix(path, r.home+"/"); ok {
+		return "~/" + rest
+	}
+	return path
+}
+
+// Text redacts free-form text, such as a log message. Every value that
+// has been redacted so far is replaced with its placeholder wherever
+// it appears in s as a whole token, as is anything that looks like an
+// IP address and the user's home directory. Values that haven't
+// already been redacted by another method can't be recognized, so
+// other methods should be used first wherever possible.
+func (r *Redactor) Text(s string) string {
+	if r == nil {
+		return s
+	}
+
+	s = addrRE.ReplaceAllStringFunc(s, func(m string) string {
+		addr, err := netip.ParseAddr(m)
+		if err != nil {
+			return m
+		}
+		return r.Addr(addr).String()
+	})
+
+	// Longer names are tried first so that a name that contains
+	// another, such as a DNS name and its host, is replaced whole.
+	names := make([]string, 0, len(r.names)+1)
+	for name := range r.names {
+		names = append(names, name)
+	}
+	if r.home != "" {
+		names = append(names, r.home)
+	}
+	slices.SortFunc(names, func(n1, n2 string) int {
+		return cmp.Or(cmp.Compare(len(n2), len(n1)), strings.Compare(n1, n2))
+	})
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		name, ok := matchToken(s, i, names)
+		if !ok {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+
+		if name == r.home {
+			b.WriteString("~")
+		} else {
+			b.WriteString(r.names[name])
+		}
+		i += len(name)
+	}
+	return b.String()
+}
+
+// matchToken returns the first of names that appears in s at i without
+// being part of a longer word, such as "dev" in "device".
+func matchToken(s string, i int, names []string) (string, bool) {
+	for _, name := range names {
+		end := i + len(name)
+		if !strings.HasPrefix(s[i:], name) {
+			continue
+		}
+		if (i > 0) && isWordByte(s[i-1]) && isWordByte(s[i]) {
+			continue
+		}
+		if (end < len(s)) && isWordByte(s[end-1]) && isWordByte(s[end]) {
+			continue
+		}
+		return name, true
+	}
+	return "", false
+}
+
+// isWordByte reports whether c can be part of a name, such as a
+// hostname.
+func isWordByte(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || c == '_' || c == '-'
+}
@@ -0,0 +1,162 @@
+package redact_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"deedles.dev/trayscale/internal/redact"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNil(t *testing.T) {
+	var r *redact.Redactor
+	require.Equal(t, "laptop", r.Name("node", "laptop"))
+	require.Equal(t, "laptop.tail1234.ts.net.", r.DNSName("laptop.tail1234.ts.net."))
+	require.Equal(t, netip.MustParseAddr("100.101.102.103"), r.Addr(netip.MustParseAddr("100.101.102.103")))
+	require.Equal(t, "/home/user/file", r.Path("/home/user/file"))
+	require.Equal(t, "100.101.102.103", r.Text("100.101.102.103"))
+}
+
+func TestNames(t *testing.T) {
+	r := redact.New("")
+	require.Equal(t, "node1", r.Name("node", "laptop"))
+	require.Equal(t, "node2", r.Name("node", "desktop"))
+	require.Equal(t, "node1", r.Name("node", "laptop"))
+	require.Equal(t, "os1", r.Name("os", "linux"))
+	require.Equal(t, "", r.Name("node", ""))
+
+	require.Equal(t, "user1@example.com", r.Email("someone@gmail.com"))
+	require.Equal(t, "node2.tailnet1.example.com.", r.DNSName("desktop.tail1234.ts.net."))
+	require.Equal(t, "tailnet1.example.com", r.Domain("tail1234.ts.net"))
+	require.Equal(t, "node3", r.DNSName("server"))
+}
+
+func TestURL(t *testing.T) {
+	r := redact.New("")
+
+	tests := []struct {
+		url      string
+		redacted string
+	}{
+		{url: "", redacted: ""},
+		{url: "https://controlplane.tailscale.com", redacted: "https://controlplane.tailscale.com"},
+		{url: "https://login.tailscale.com/admin", redacted: "https://login.tailscale.com/admin"},
+		{url: "https://headscale.home.arpa", redacted: "https://controlplane1.example.com"},
+		{url: "https://headscale.home.arpa:8080/", redacted: "https://controlplane2.example.com"},
+		{url: "https://headscale.home.arpa", redacted: "https://controlplane1.example.com"},
+		{url: "not a url", redacted: redact.ControlURL},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			require.Equal(t, test.redacted, r.URL(test.url))
+		})
+	}
+}
+
+func TestAddr(t *testing.T) {
+	r := redact.New("")
+
+	tests := []struct {
+		addr     string
+		redacted string
+	}{
+		{addr: "100.101.102.103", redacted: "100.64.0.1"},
+		{addr: "100.70.1.2", redacted: "100.64.0.2"},
+		{addr: "100.101.102.103", redacted: "100.64.0.1"},
+		{addr: "fd7a:115c:a1e0::1234", redacted: "fd7a:115c:a1e0::1"},
+		{addr: "203.0.113.40", redacted: "198.18.0.1"},
+		{addr: "192.168.1.20", redacted: "198.18.0.2"},
+		{addr: "2600:1700::5", redacted: "2001:db8::1"},
+		{addr: "127.0.0.1", redacted: "127.0.0.1"},
+		{addr: "::", redacted: "::"},
+		{addr: "fe80::1", redacted: "fe80::1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.addr, func(t *testing.T) {
+			redacted := r.Addr(netip.MustParseAddr(test.addr))
+			require.Equal(t, test.redacted, redacted.String())
+		})
+	}
+}
+
+func TestAddrOverflow(t *testing.T) {
+	r := redact.New("")
+	var last netip.Addr
+	for i := range 300 {
+		last = r.Addr(netip.AddrFrom4([4]byte{100, 100, byte(i >> 8), byte(i)}))
+	}
+	require.Equal(t, "100.64.1.44", last.String())
+}
+
+func TestAddrPort(t *testing.T) {
+	r := redact.New("")
+	require.Equal(t, "198.18.0.1:41641", r.AddrPort(netip.MustParseAddrPort("203.0.113.40:41641")).String())
+	require.False(t, r.AddrPort(netip.AddrPort{}).IsValid())
+}
+
+func TestPrefix(t *testing.T) {
+	r := redact.New("")
+	require.Equal(t, "0.0.0.0/0", r.Prefix(netip.MustParsePrefix("0.0.0.0/0")).String())
+	require.Equal(t, "100.64.0.1/32", r.Prefix(netip.MustParsePrefix("100.101.102.103/32")).String())
+	require.Equal(t, "198.18.0.0/24", r.Prefix(netip.MustParsePrefix("192.168.1.0/24")).String())
+}
+
+func TestPath(t *testing.T) {
+	r := redact.New("/home/user/")
+	require.Equal(t, "~", r.Path("/home/user"))
+	require.Equal(t, "~/Downloads", r.Path("/home/user/Downloads"))
+	require.Equal(t, "/home/username", r.Path("/home/username"))
+	require.Equal(t, "/tmp", r.Path("/tmp"))
+}
+
+func TestText(t *testing.T) {
+	r := redact.New("/home/user")
+	r.DNSName("laptop.tail1234.ts.net.")
+	r.Name("node", "laptop-2")
+	r.Name("node", "dev")
+
+	tests := []struct {
+		name     string
+		text     string
+		redacted string
+	}{
+		{
+			name:     "Names",
+			text:     "peer=laptop.tail1234.ts.net. other=laptop-2 host=laptop",
+			redacted: "peer=node1.tailnet1.example.com. other=node2 host=node1",
+		},
+		{
+			name:     "Addresses",
+			text:     "dial 100.101.102.103:41641 via [fd7a:115c:a1e0::9]:41641 from ::1",
+			redacted: "dial 100.64.0.1:41641 via [fd7a:115c:a1e0::1]:41641 from ::1",
+		},
+		{
+			name:     "NotAddresses",
+			text:     "time=12:34:56 version=1.2.3 mac=aa:bb:cc:dd:ee:ff",
+			redacted: "time=12:34:56 version=1.2.3 mac=aa:bb:cc:dd:ee:ff",
+		},
+		{
+			name:     "ShortNames",
+			text:     "device=dev devices=dev,laptops dev-laptop",
+			redacted: "device=node3 devices=node3,laptops dev-laptop",
+		},
+		{
+			name:     "Home",
+			text:     "save file path=/home/user/Downloads/file.txt",
+			redacted: "save file path=~/Downloads/file.txt",
+		},
+		{
+			name:     "NotHome",
+			text:     "path=/home/username/file.txt",
+			redacted: "path=/home/username/file.txt",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.redacted, r.Text(test.text))
+		})
+	}
+}
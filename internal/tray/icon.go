@@ -0,0 +1,261 @@
+package tray
+
+import (
+	"bytes"
+	_ "embed"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+
+	"deedles.dev/tray"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+var (
+	//go:embed status-icon-active.png
+	statusIconActiveData []byte
+	statusIconActive     = decode(statusIconActiveData)
+
+	//go:embed status-icon-inactive.png
+	statusIconInactiveData []byte
+	statusIconInactive     = decode(statusIconInactiveData)
+
+	// iconSizes are the sizes, in pixels, at which the status icon is
+	// rendered. The StatusNotifierItem host picks whichever fits its
+	// panel best.
+	iconSizes = []int{16, 22, 24, 32, 48, 64}
+
+	badgeColor    = color.RGBA{0xE0, 0x1B, 0x24, 0xFF}
+	warningColor  = color.RGBA{0xF6, 0xD3, 0x2D, 0xFF}
+	exitNodeColor = color.RGBA{0x35, 0x84, 0xE4, 0xFF}
+	markColor     = color.RGBA{0x24, 0x1F, 0x31, 0xFF}
+)
+
+func decode(data []byte) *image.RGBA {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		panic(err)
+	}
+
+	dst := image.NewRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Src)
+	return dst
+}
+
+// IconTheme selects the color variant of the status icon.
+type IconTheme int
+
+const (
+	// IconThemeLight is intended for light panels and draws the icon in
+	// dark colors.
+	IconThemeLight IconTheme = iota
+
+	// IconThemeDark is intended for dark panels and draws the icon in
+	// light colors.
+	IconThemeDark
+)
+
+// iconState is everything that affects the appearance of the status
+// icon. It is comparable so that it can be used to cache rendered
+// icons.
+type iconState struct {
+	Online   bool
+	ExitNode bool
+	Warning  bool
+	Files    int
+	Theme    IconTheme
+}
+
+// maxBadgeFiles is the largest file count shown exactly in the badge.
+// Anything above it is shown as "9+".
+const maxBadgeFiles = 9
+
+func (s iconState) badgeText() string {
+	if s.Files > maxBadgeFiles {
+		return strconv.Itoa(maxBadgeFiles) + "+"
+	}
+	return strconv.Itoa(s.Files)
+}
+
+// render composites the icon described by s at every size in
+// iconSizes.
+func (s iconState) render() []*tray.Pixmap {
+	base := statusIconInactive
+	if s.Online {
+		base = statusIconActive
+	}
+
+	master := image.NewRGBA(base.Bounds())
+	draw.Draw(master, master.Bounds(), base, base.Bounds().Min, draw.Src)
+	if s.Theme == IconThemeDark {
+		invert(master)
+	}
+
+	if s.ExitNode {
+		drawExitNodeMarker(master)
+	}
+	if s.Warning {
+		drawWarning(master)
+	}
+	if s.Files > 0 {
+		drawBadge(master, s.badgeText())
+	}
+
+	pixmaps := make([]*tray.Pixmap, 0, len(iconSizes))
+	for _, size := range iconSizes {
+		dst := image.NewRGBA(image.Rect(0, 0, size, size))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), master, master.Bounds(), draw.Src, nil)
+		pm := tray.ToPixmap(dst)
+		pixmaps = append(pixmaps, &pm)
+	}
+	return pixmaps
+}
+
+// invert inverts the colors of img in place while leaving its alpha
+// channel alone.
+func invert(img *image.RGBA) {
+	for i := 0; i < len(img.Pix); i += 4 {
+		a := img.Pix[i+3]
+		img.Pix[i+0] = a - img.Pix[i+0]
+		img.Pix[i+1] = a - img.Pix[i+1]
+		img.Pix[i+2] = a - img.Pix[i+2]
+	}
+}
+
+// fill draws c over every pixel in r of dst for which inside returns
+// true. Coordinates passed to inside are relative to r and scaled to
+// [0, 1]. Each pixel is supersampled to antialias the edges.
+func fill(dst *image.RGBA, r image.Rectangle, c color.Color, inside func(x, y float64) bool) {
+	const samples = 4
+
+	w, h := float64(r.Dx()), float64(r.Dy())
+	src := image.NewUniform(c)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			var hits int
+			for sy := range samples {
+				for sx := range samples {
+					fx := (float64(x-r.Min.X) + (float64(sx)+0.5)/samples) / w
+					fy := (float64(y-r.Min.Y) + (float64(sy)+0.5)/samples) / h
+					if inside(fx, fy) {
+						hits++
+					}
+				}
+			}
+			if hits == 0 {
+				continue
+			}
+
+			mask := image.NewUniform(color.Alpha{uint8(hits * 0xFF / (samples * samples))})
+			pr := image.Rect(x, y, x+1, y+1)
+			draw.DrawMask(dst, pr, src, image.Point{}, mask, image.Point{}, draw.Over)
+		}
+	}
+}
+
+func inCircle(cx, cy, r float64) func(x, y float64) bool {
+	return func(x, y float64) bool {
+		return math.Hypot(x-cx, y-cy) <= r
+	}
+}
+
+func inSegment(x1, y1, x2, y2, width float64) func(x, y float64) bool {
+	dx, dy := x2-x1, y2-y1
+	length2 := dx*dx + dy*dy
+	return func(x, y float64) bool {
+		t := max(0, min(1, ((x-x1)*dx+(y-y1)*dy)/length2))
+		return math.Hypot(x-(x1+t*dx), y-(y1+t*dy)) <= width/2
+	}
+}
+
+func inTriangle(x1, y1, x2, y2, x3, y3 float64) func(x, y float64) bool {
+	sign := func(px, py, ax, ay, bx, by float64) float64 {
+		return (px-bx)*(ay-by) - (ax-bx)*(py-by)
+	}
+	return func(x, y float64) bool {
+		d1 := sign(x, y, x1, y1, x2, y2)
+		d2 := sign(x, y, x2, y2, x3, y3)
+		d3 := sign(x, y, x3, y3, x1, y1)
+		neg := d1 < 0 || d2 < 0 || d3 < 0
+		pos := d1 > 0 || d2 > 0 || d3 > 0
+		return !(neg && pos)
+	}
+}
+
+func anyOf(fs ...func(x, y float64) bool) func(x, y float64) bool {
+	return func(x, y float64) bool {
+		for _, f := range fs {
+			if f(x, y) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// corner returns the square occupying the given fraction of img's
+// size in one of its corners.
+func corner(img *image.RGBA, frac float64, right, bottom bool) image.Rectangle {
+	b := img.Bounds()
+	size := int(float64(b.Dx()) * frac)
+	r := image.Rect(0, 0, size, size).Add(b.Min)
+	if right {
+		r = r.Add(image.Pt(b.Dx()-size, 0))
+	}
+	if bottom {
+		r = r.Add(image.Pt(0, b.Dy()-size))
+	}
+	return r
+}
+
+// drawBadge draws a count badge containing text in the top-right
+// corner of img.
+func drawBadge(img *image.RGBA, text string) {
+	r := corner(img, 0.5, true, false)
+	fill(img, r, badgeColor, inCircle(0.5, 0.5, 0.5))
+
+	face := basicfont.Face7x13
+	d := font.Drawer{Face: face, Src: image.White}
+	tw := d.MeasureString(text).Ceil()
+	th := face.Ascent
+
+	glyphs := image.NewRGBA(image.Rect(0, 0, tw, th))
+	d.Dst = glyphs
+	d.Dot = fixed.P(0, th)
+	d.DrawString(text)
+
+	// Scale the glyphs up to fill most of the badge while keeping their
+	// aspect ratio.
+	scale := min(float64(r.Dx())*0.6/float64(tw), float64(r.Dy())*0.6/float64(th))
+	gw, gh := int(float64(tw)*scale), int(float64(th)*scale)
+	gr := image.Rect(0, 0, gw, gh).Add(r.Min).Add(image.Pt((r.Dx()-gw)/2, (r.Dy()-gh)/2))
+	draw.ApproxBiLinear.Scale(img, gr, glyphs, glyphs.Bounds(), draw.Over, nil)
+}
+
+// drawWarning draws a warning triangle in the bottom-left corner of
+// img.
+func drawWarning(img *image.RGBA) {
+	r := corner(img, 0.45, false, true)
+	fill(img, r, warningColor, inTriangle(0.5, 0.02, 0.98, 0.94, 0.02, 0.94))
+	fill(img, r, markColor, anyOf(
+		inSegment(0.5, 0.32, 0.5, 0.62, 0.12),
+		inCircle(0.5, 0.78, 0.07),
+	))
+}
+
+// drawExitNodeMarker draws a marker indicating that an exit node is in
+// use in the bottom-right corner of img.
+func drawExitNodeMarker(img *image.RGBA) {
+	r := corner(img, 0.45, true, true)
+	fill(img, r, exitNodeColor, inCircle(0.5, 0.5, 0.5))
+	fill(img, r, color.White, anyOf(
+		inSegment(0.3, 0.7, 0.7, 0.3, 0.12),
+		inSegment(0.4, 0.3, 0.7, 0.3, 0.12),
+		inSegment(0.7, 0.3, 0.7, 0.6, 0.12),
+	))
+}
@@ -1,45 +1,29 @@
 package tray
 
 import (
-	"bytes"
-	_ "embed"
+	"cmp"
+	"context"
 	"fmt"
-	"image/png"
+	"log/slog"
 	"slices"
 	"sync"
 	"unique"
 
 	"deedles.dev/tray"
 	"deedles.dev/trayscale/internal/tsutil"
+	"github.com/godbus/dbus/v5"
+	"tailscale.com/ipn"
+	"tailscale.com/tailcfg"
 )
 
 var (
-	//go:embed status-icon-active.png
-	statusIconActiveData []byte
-	statusIconActive     = decode(statusIconActiveData)
-
-	//go:embed status-icon-inactive.png
-	statusIconInactiveData []byte
-	statusIconInactive     = decode(statusIconInactiveData)
-
-	//go:embed status-icon-exit-node.png
-	statusIconExitNodeData []byte
-	statusIconExitNode     = decode(statusIconExitNodeData)
-
 	selfHandle       = unique.Make("self")
 	connToggleHandle = unique.Make("connToggle")
 	exitToggleHandle = unique.Make("exitToggle")
 	statusIconHandle = unique.Make("statusIcon")
+	peersHandle      = unique.Make("peers")
 )
 
-func decode(data []byte) tray.Pixmap {
-	img, err := png.Decode(bytes.NewReader(data))
-	if err != nil {
-		panic(err)
-	}
-	return tray.ToPixmap(img)
-}
-
 func handler(f func()) tray.MenuItemProp {
 	return tray.MenuItemHandler(tray.ClickedHandler(func(data any, timestamp uint32) error {
 		f()
@@ -47,37 +31,134 @@ func handler(f func()) tray.MenuItemProp {
 	}))
 }
 
+// ClickAction is an action performed when the tray icon is clicked.
+type ClickAction int
+
+const (
+	// ClickNone does nothing.
+	ClickNone ClickAction = iota
+
+	// ClickToggleWindow shows the main window, or hides it if it is
+	// already shown.
+	ClickToggleWindow
+
+	// ClickConnToggle connects or disconnects from the tailnet.
+	ClickConnToggle
+
+	// ClickExitToggle enables or disables the exit node.
+	ClickExitToggle
+
+	// ClickMenu opens the tray icon's menu. It is only supported for
+	// the primary click, as the host decides how to open the menu.
+	ClickMenu
+)
+
 type Tray struct {
-	OnShow       func()
-	OnConnToggle func()
-	OnExitToggle func()
-	OnSelfNode   func()
-	OnQuit       func()
+	OnShow         func()
+	OnToggleWindow func()
+	OnConnToggle   func()
+	OnExitToggle   func()
+	OnSelfNode     func()
+	OnQuit         func()
+
+	// OnSendClipboard is called when the user selects a peer to send
+	// the contents of the clipboard to.
+	OnSendClipboard func(peerID tailcfg.StableNodeID, peerName string)
 
 	m    sync.Mutex
+	stop context.CancelFunc
 	item *tray.Item
 	prev map[unique.Handle[string]][]any
 
+	primary   ClickAction
+	secondary ClickAction
+
+	status *tsutil.IPNStatus
+	files  int
+	theme  IconTheme
+	icons  map[iconState][]*tray.Pixmap
+
 	showItem       *tray.MenuItem
 	connToggleItem *tray.MenuItem
 	exitToggleItem *tray.MenuItem
 	selfNodeItem   *tray.MenuItem
 	quitItem       *tray.MenuItem
+
+	sendClipboardItem *tray.MenuItem
+	peerItems         []*tray.MenuItem
 }
 
+// Start shows the tray icon. If no StatusNotifierWatcher is currently
+// available on the session bus, the icon is registered as soon as one
+// appears. The icon is also registered again if the watcher is
+// replaced, such as when the panel restarts.
 func (t *Tray) Start(status *tsutil.IPNStatus) error {
-	if t.item != nil {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if t.stop != nil {
 		return nil
 	}
+	t.status = status
 
-	t.m.Lock()
-	defer t.m.Unlock()
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return fmt.Errorf("connect to session bus: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	owner, changes, err := watchName(ctx, conn, watcherName)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("watch for %v: %w", watcherName, err)
+	}
+	t.stop = cancel
+	go t.watch(changes)
+
+	if owner == "" {
+		slog.Info("no StatusNotifierWatcher available, waiting for one to appear")
+		return nil
+	}
+	return t.register()
+}
+
+// watch registers the icon again every time that the owner of the
+// StatusNotifierWatcher name changes.
+func (t *Tray) watch(changes <-chan string) {
+	for owner := range changes {
+		t.m.Lock()
+		if t.stop == nil {
+			t.m.Unlock()
+			return
+		}
+
+		err := t.unregister()
+		if err != nil {
+			slog.Error("close stale tray icon", "err", err)
+		}
+
+		if owner != "" {
+			slog.Info("StatusNotifierWatcher appeared, registering tray icon", "owner", owner)
+			err := t.register()
+			if err != nil {
+				slog.Error("register tray icon", "owner", owner, "err", err)
+			}
+		}
+		t.m.Unlock()
+	}
+}
 
+func (t *Tray) register() error {
 	item, err := tray.New(
 		tray.ItemID("dev.deedles.Trayscale"),
 		tray.ItemTitle("Trayscale"),
+		tray.ItemIsMenu(t.primary == ClickMenu),
 		tray.ItemHandler(tray.ActivateHandler(func(x, y int) error {
-			t.OnShow()
+			t.click(func() ClickAction { return t.primary })
+			return nil
+		})),
+		tray.ItemHandler(tray.SecondaryActivateHandler(func(x, y int) error {
+			t.click(func() ClickAction { return t.secondary })
 			return nil
 		})),
 	)
@@ -94,22 +175,58 @@ func (t *Tray) Start(status *tsutil.IPNStatus) error {
 	t.connToggleItem, _ = menu.AddChild(handler(t.OnConnToggle))
 	t.exitToggleItem, _ = menu.AddChild(handler(t.OnExitToggle))
 	t.selfNodeItem, _ = menu.AddChild(handler(t.OnSelfNode))
+	t.sendClipboardItem, _ = menu.AddChild(tray.MenuItemLabel("Send clipboard to"), tray.MenuItemEnabled(false))
+	t.peerItems = nil
 	menu.AddChild(tray.MenuItemType(tray.Separator))
 	t.quitItem, _ = menu.AddChild(tray.MenuItemLabel("Quit"), handler(t.OnQuit))
 
-	t.update(status)
+	if t.status != nil {
+		t.update(t.status)
+	}
 
 	return nil
 }
 
-func (t *Tray) Close() error {
+// SetClickActions changes the actions performed when the tray icon
+// is clicked with the primary and secondary, usually middle, mouse
+// buttons.
+func (t *Tray) SetClickActions(primary, secondary ClickAction) {
 	if t == nil {
-		return nil
+		return
 	}
 
 	t.m.Lock()
 	defer t.m.Unlock()
 
+	t.primary = primary
+	t.secondary = secondary
+	if t.item != nil {
+		t.item.SetProps(tray.ItemIsMenu(primary == ClickMenu))
+	}
+}
+
+// click performs the click action returned by action. The action is
+// looked up while holding the lock, but performed without it.
+func (t *Tray) click(action func() ClickAction) {
+	t.m.Lock()
+	a := action()
+	t.m.Unlock()
+
+	var f func()
+	switch a {
+	case ClickToggleWindow:
+		f = t.OnToggleWindow
+	case ClickConnToggle:
+		f = t.OnConnToggle
+	case ClickExitToggle:
+		f = t.OnExitToggle
+	}
+	if f != nil {
+		f()
+	}
+}
+
+func (t *Tray) unregister() error {
 	if t.item == nil {
 		return nil
 	}
@@ -120,20 +237,55 @@ func (t *Tray) Close() error {
 	return err
 }
 
+func (t *Tray) Close() error {
+	if t == nil {
+		return nil
+	}
+
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if t.stop != nil {
+		t.stop()
+		t.stop = nil
+	}
+
+	return t.unregister()
+}
+
 func (t *Tray) Update(s tsutil.Status) {
 	if t == nil {
 		return
 	}
 
-	status, ok := s.(*tsutil.IPNStatus)
-	if !ok {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	switch s := s.(type) {
+	case *tsutil.IPNStatus:
+		t.status = s
+		t.update(s)
+	case *tsutil.FileStatus:
+		t.files = len(s.Files)
+		if t.status != nil {
+			t.updateStatusIcon(t.status)
+		}
+	}
+}
+
+// SetIconTheme changes the color variant used for the status icon.
+func (t *Tray) SetIconTheme(theme IconTheme) {
+	if t == nil {
 		return
 	}
 
 	t.m.Lock()
 	defer t.m.Unlock()
 
-	t.update(status)
+	t.theme = theme
+	if t.status != nil {
+		t.updateStatusIcon(t.status)
+	}
 }
 
 func (t *Tray) dirty(key unique.Handle[string], vals ...any) bool {
@@ -174,25 +326,96 @@ func (t *Tray) update(status *tsutil.IPNStatus) {
 			tray.MenuItemEnabled(connected),
 		)
 	}
+
+	peers := fileTargets(status)
+	if t.dirty(peersHandle, peers...) {
+		t.updatePeers(peers)
+	}
+}
+
+// updatePeers replaces the peers listed in the "Send clipboard to"
+// submenu.
+func (t *Tray) updatePeers(peers []any) {
+	for _, item := range t.peerItems {
+		item.Remove()
+	}
+	t.peerItems = t.peerItems[:0]
+
+	for _, p := range peers {
+		p := p.(peerTarget)
+		item, err := t.sendClipboardItem.AddChild(
+			tray.MenuItemLabel(p.Name),
+			handler(func() { t.OnSendClipboard(p.ID, p.Name) }),
+		)
+		if err != nil {
+			slog.Error("add peer to tray menu", "peer", p.ID, "err", err)
+			continue
+		}
+		t.peerItems = append(t.peerItems, item)
+	}
+
+	t.sendClipboardItem.SetProps(tray.MenuItemEnabled(len(t.peerItems) > 0))
 }
 
 func (t *Tray) updateStatusIcon(status *tsutil.IPNStatus) {
-	newIcon := statusIcon(status)
-	if !t.dirty(statusIconHandle, newIcon) {
+	if t.item == nil {
+		return
+	}
+
+	state := iconState{
+		Online:   status.Online(),
+		ExitNode: status.Online() && status.ExitNodeActive(),
+		Warning:  status.NeedsAuth() || status.State == ipn.NeedsMachineAuth,
+		Files:    min(t.files, maxBadgeFiles+1),
+		Theme:    t.theme,
+	}
+	if !t.dirty(statusIconHandle, state) {
 		return
 	}
 
-	t.item.SetProps(tray.ItemIconPixmap(newIcon))
+	icon, ok := t.icons[state]
+	if !ok {
+		icon = state.render()
+		if t.icons == nil {
+			t.icons = make(map[iconState][]*tray.Pixmap)
+		}
+		t.icons[state] = icon
+	}
+
+	t.item.SetProps(tray.ItemIconPixmap(icon...))
+}
+
+type peerTarget struct {
+	ID   tailcfg.StableNodeID
+	Name string
 }
 
-func statusIcon(status *tsutil.IPNStatus) *tray.Pixmap {
+// fileTargets returns the peers that files can be sent to as
+// peerTargets sorted by name.
+func fileTargets(status *tsutil.IPNStatus) []any {
 	if !status.Online() {
-		return &statusIconInactive
+		return nil
 	}
-	if status.ExitNodeActive() {
-		return &statusIconExitNode
+
+	var peers []peerTarget
+	for id, peer := range status.Peers {
+		if !status.FileTargets.Contains(id) || tsutil.IsMullvad(peer) {
+			continue
+		}
+		peers = append(peers, peerTarget{ID: id, Name: peer.DisplayName(true)})
+	}
+	slices.SortFunc(peers, func(p1, p2 peerTarget) int {
+		return cmp.Or(
+			cmp.Compare(p1.Name, p2.Name),
+			cmp.Compare(p1.ID, p2.ID),
+		)
+	})
+
+	targets := make([]any, 0, len(peers))
+	for _, p := range peers {
+		targets = append(targets, p)
 	}
-	return &statusIconActive
+	return targets
 }
 
 func selfTitle(status *tsutil.IPNStatus) (string, bool) {
@@ -0,0 +1,93 @@
+package tray
+
+import (
+	"context"
+	"errors"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// watcherName is the well-known bus name of the StatusNotifierWatcher
+// with which tray icons must be registered.
+const watcherName = "org.kde.StatusNotifierWatcher"
+
+// watchName watches conn for changes of the owner of the well-known
+// bus name. It returns the current owner, or an empty string if the
+// name has no owner, and a channel that yields each new owner as the
+// name changes hands. An empty string is sent when the name is
+// released. The channel is closed when ctx is canceled.
+func watchName(ctx context.Context, conn *dbus.Conn, name string) (string, <-chan string, error) {
+	match := []dbus.MatchOption{
+		dbus.WithMatchSender("org.freedesktop.DBus"),
+		dbus.WithMatchObjectPath("/org/freedesktop/DBus"),
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg(0, name),
+	}
+	err := conn.AddMatchSignalContext(ctx, match...)
+	if err != nil {
+		return "", nil, err
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+
+	// The match is added before checking the current owner so that no
+	// change can slip through in between.
+	var owner string
+	err = conn.BusObject().CallWithContext(ctx, "org.freedesktop.DBus.GetNameOwner", 0, name).Store(&owner)
+	if err != nil {
+		derr, ok := errors.AsType[dbus.Error](err)
+		if !ok || derr.Name != "org.freedesktop.DBus.Error.NameHasNoOwner" {
+			conn.RemoveSignal(signals)
+			conn.RemoveMatchSignal(match...)
+			return "", nil, err
+		}
+		owner = ""
+	}
+
+	changes := make(chan string)
+	go func() {
+		defer close(changes)
+		defer conn.RemoveMatchSignal(match...)
+		defer conn.RemoveSignal(signals)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig, ok := <-signals:
+				if !ok {
+					return
+				}
+
+				newOwner, ok := ownerChange(sig, name)
+				if !ok {
+					continue
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case changes <- newOwner:
+				}
+			}
+		}
+	}()
+
+	return owner, changes, nil
+}
+
+// ownerChange returns the new owner of name if sig is a
+// NameOwnerChanged signal for it.
+func ownerChange(sig *dbus.Signal, name string) (string, bool) {
+	if sig.Name != "org.freedesktop.DBus.NameOwnerChanged" || len(sig.Body) != 3 {
+		return "", false
+	}
+	if n, _ := sig.Body[0].(string); n != name {
+		return "", false
+	}
+
+	newOwner, ok := sig.Body[2].(string)
+	return newOwner, ok
+}
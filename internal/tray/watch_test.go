@@ -0,0 +1,126 @@
+package tray
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/require"
+)
+
+const testBusConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:dir=%DIR%</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// startBus starts a private dbus-daemon for the duration of the test
+// and returns its address.
+func startBus(t *testing.T) string {
+	path, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not available")
+	}
+
+	dir := t.TempDir()
+	config := filepath.Join(dir, "bus.conf")
+	err = os.WriteFile(config, []byte(strings.ReplaceAll(testBusConfig, "%DIR%", dir)), 0o644)
+	require.NoError(t, err)
+
+	cmd := exec.Command(path, "--config-file="+config, "--nofork", "--nopidfile", "--print-address=1")
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	addr, err := bufio.NewReader(stdout).ReadString('\n')
+	require.NoError(t, err)
+	return strings.TrimSpace(addr)
+}
+
+func connect(t *testing.T, addr string) *dbus.Conn {
+	conn, err := dbus.Connect(addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// startWatcher claims the StatusNotifierWatcher name on a new
+// connection, standing in for a panel.
+func startWatcher(t *testing.T, addr string) *dbus.Conn {
+	conn := connect(t, addr)
+	reply, err := conn.RequestName(watcherName, dbus.NameFlagDoNotQueue)
+	require.NoError(t, err)
+	require.Equal(t, dbus.RequestNameReplyPrimaryOwner, reply)
+	return conn
+}
+
+func nextOwner(t *testing.T, changes <-chan string) string {
+	select {
+	case owner, ok := <-changes:
+		require.True(t, ok, "changes channel closed")
+		return owner
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for owner change")
+		return ""
+	}
+}
+
+func TestWatchName(t *testing.T) {
+	addr := startBus(t)
+	conn := connect(t, addr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	owner, changes, err := watchName(ctx, conn, watcherName)
+	require.NoError(t, err)
+	require.Empty(t, owner, "no watcher should be running yet")
+
+	// The panel starts late.
+	watcher := startWatcher(t, addr)
+	require.Equal(t, watcher.Names()[0], nextOwner(t, changes))
+
+	// The panel exits.
+	require.NoError(t, watcher.Close())
+	require.Empty(t, nextOwner(t, changes))
+
+	// The panel restarts.
+	watcher = startWatcher(t, addr)
+	require.Equal(t, watcher.Names()[0], nextOwner(t, changes))
+
+	cancel()
+	select {
+	case _, ok := <-changes:
+		require.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("changes channel not closed after cancel")
+	}
+}
+
+func TestWatchNameExistingOwner(t *testing.T) {
+	addr := startBus(t)
+	watcher := startWatcher(t, addr)
+	conn := connect(t, addr)
+
+	owner, _, err := watchName(t.Context(), conn, watcherName)
+	require.NoError(t, err)
+	require.Equal(t, watcher.Names()[0], owner)
+}
@@ -226,6 +226,53 @@ func SetControlURL(ctx context.Context, controlURL string) error {
 	return nil
 }
 
+// ErrAlreadyLoggedIn is returned by LoginWithAuthKey if the node is
+// already logged in, in which case tailscaled would ignore the key.
+var ErrAlreadyLoggedIn = errors.New("already logged in; log out or add another account before using an auth key")
+
+// LoginWithAuthKey logs in to the control server with a pre-auth key
+// and starts the connection. If tags or hostname are set, they are
+// advertised for the node. It fails with ErrAlreadyLoggedIn unless
+// the node needs to log in.
+func LoginWithAuthKey(ctx context.Context, authKey string, tags []string, hostname string) error {
+	for _, tag := range tags {
+		err := tailcfg.CheckTag(tag)
+		if err != nil {
+			return fmt.Errorf("invalid tag %q: %w", tag, err)
+		}
+	}
+
+	st, err := GetStatus(ctx)
+	if err != nil {
+		return err
+	}
+	if st.HaveNodeKey && (st.BackendState != ipn.NeedsLogin.String()) {
+		return ErrAlreadyLoggedIn
+	}
+
+	prefs, err := Prefs(ctx)
+	if err != nil {
+		return fmt.Errorf("get prefs: %w", err)
+	}
+	prefs.WantRunning = true
+	if len(tags) > 0 {
+		prefs.AdvertiseTags = tags
+	}
+	if hostname != "" {
+		prefs.Hostname = hostname
+	}
+
+	err = localClient.Start(ctx, ipn.Options{
+		AuthKey:     authKey,
+		UpdatePrefs: prefs,
+	})
+	if err != nil {
+		return fmt.Errorf("start local client: %w", err)
+	}
+
+	return nil
+}
+
 func NetCheck(ctx context.Context, full bool) (*netcheck.Report, *tailcfg.DERPMap, error) {
 	err := netcheckClient.Standalone(ctx, "")
 	if err != nil {
@@ -282,3 +329,50 @@ func SwitchProfile(ctx context.Context, id ipn.ProfileID) error {
 func StartLogin(ctx context.Context) error {
 	return localClient.StartLoginInteractive(ctx)
 }
+
+// AddProfile switches to a new, empty profile. The new profile needs
+// to be logged in to before it can be used.
+func AddProfile(ctx context.Context) error {
+	return localClient.SwitchToEmptyProfile(ctx)
+}
+
+// Logout logs the current profile out of its tailnet. The profile is
+// kept and can be logged into again.
+func Logout(ctx context.Context) error {
+	return localClient.Logout(ctx)
+}
+
+// DeleteProfile logs out of and removes the profile with the given
+// ID. If it is the current profile, the daemon switches to an empty
+// profile.
+func DeleteProfile(ctx context.Context, id ipn.ProfileID) error {
+	return localClient.DeleteProfile(ctx, id)
+}
+
+// BugReport asks tailscaled to write a marker to its logs so that the
+// logs from around the time of a problem can be found. It returns the
+// marker, which should be included in the bug report.
+func BugReport(ctx context.Context, note string) (string, error) {
+	return localClient.BugReport(ctx, note)
+}
+
+// WatchLinkChanges calls f whenever the network monitor detects a
+// major change to the network, such as switching to a different
+// network interface or waking from sleep, until the returned function
+// is called.
+func WatchLinkChanges(f func()) (stop func()) {
+	if monitor == nil {
+		return func() {}
+	}
+
+	unregister := monitor.RegisterChangeCallback(func(delta *netmon.ChangeDelta) {
+		if delta.IsInitialState {
+			return
+		}
+		if delta.RebindLikelyRequired || delta.TimeJumped() {
+			f()
+		}
+	})
+	monitor.Start()
+	return unregister
+}
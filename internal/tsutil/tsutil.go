@@ -7,8 +7,6 @@ import (
 	"tailscale.com/tailcfg"
 )
 
-const AdminDashboardURL = "https://tailscale.com/admin"
-
 // IsMullvad returns true if peer is a Mullvad exit node.
 func IsMullvad(peer tailcfg.NodeView) bool {
 	return peer.Tags().ContainsFunc(func(tag string) bool {
@@ -12,8 +12,13 @@ import (
 	"time"
 
 	"deedles.dev/trayscale/internal/autosave"
+	"deedles.dev/trayscale/internal/clipfile"
+	"deedles.dev/trayscale/internal/errreport"
 	"deedles.dev/trayscale/internal/gutil"
+	"deedles.dev/trayscale/internal/logbuf"
 	"deedles.dev/trayscale/internal/metadata"
+	"deedles.dev/trayscale/internal/netreport"
+	"deedles.dev/trayscale/internal/preview"
 	"deedles.dev/trayscale/internal/tray"
 	"deedles.dev/trayscale/internal/tsutil"
 	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
@@ -21,7 +26,6 @@ import (
 	"github.com/diamondburned/gotk4/pkg/gio/v2"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
-	"github.com/inhies/go-bytesize"
 	"tailscale.com/client/tailscale/apitype"
 	"tailscale.com/tailcfg"
 )
@@ -40,19 +44,34 @@ type App struct {
 	settings *gio.Settings
 	tray     *tray.Tray
 
+	loginDialog *adw.AlertDialog
+
 	spinnum        int
 	operatorCheck  bool
 	files          *[]apitype.WaitingFile
 	autoSaving     sync.Map // waiting-file name -> struct{} while save is in flight
 	autoSaveFailed sync.Map // waiting-file name -> struct{} after a failed auto-save attempt
 	autoSaveDirBad string   // destination dir last logged as unusable; avoids log spam
+
+	filesSeen map[string]time.Time // waiting-file name -> when it was first seen
+	previews  preview.Cache
+	errors    errreport.Recent
+
+	logs       logbuf.Buffer
+	logsWindow *LogsWindow
+
+	netReport       netreport.Report
+	netcheckCancel  func()
+	netcheckHistory []netreport.Summary
+	netcheckTimer   glib.SourceHandle
+	linkChangeTimer glib.SourceHandle
 }
 
 func (a *App) clip(v *glib.Value) {
 	gdk.DisplayGetDefault().Clipboard().Set(v)
 }
 
-func (a *App) notify(title, body string) {
+func newNotification(title, body string) *gio.Notification {
 	icon, iconerr := gio.NewIconForString(metadata.AppID)
 
 	n := gio.NewNotification(title)
@@ -60,8 +79,11 @@ func (a *App) notify(title, body string) {
 	if iconerr == nil {
 		n.SetIcon(icon)
 	}
+	return n
+}
 
-	a.app.SendNotification("tailscale-status", n)
+func (a *App) notify(title, body string) {
+	a.app.SendNotification("tailscale-status", newNotification(title, body))
 }
 
 func (a *App) spin() {
@@ -118,6 +140,12 @@ func (a *App) update(status tsutil.Status) {
 			useExitNodeAction.SetState(glib.NewVariantBoolean(status.ExitNodeActive()))
 		}
 
+		adminDashboardAction, ok := gutil.Assert[*gio.SimpleAction](a.app.LookupAction("admin_dashboard"))
+		if ok {
+			_, known := a.adminURL(status)
+			adminDashboardAction.SetEnabled(known)
+		}
+
 		if online && !a.operatorCheck {
 			a.operatorCheck = true
 			if !status.OperatorIsCurrent() {
@@ -129,6 +157,8 @@ func (a *App) update(status tsutil.Status) {
 			a.files = nil
 		}
 
+		a.updateLoginDialog(status)
+
 		a.tray.Update(status)
 
 		if a.win != nil {
@@ -142,19 +172,26 @@ func (a *App) update(status tsutil.Status) {
 		if a.files != nil {
 			for _, file := range status.Files {
 				if !slices.Contains(*a.files, file) {
+					if kind, ok := clipfile.Parse(file.Name); ok {
+						go a.notifyClipboardFile(context.Background(), file, kind)
+						continue
+					}
+
 					// Skip the manual-save notification when auto-save will
 					// handle the file immediately.
 					if autoSaveOn {
 						continue
 					}
-					body := fmt.Sprintf("%v (%v)", file.Name, bytesize.ByteSize(file.Size))
-					a.notify("New Incoming File", body)
+					a.notifyIncomingFile(file)
 				}
 			}
 		}
 		a.files = &status.Files
+		a.updateFilesSeen()
 		a.maybeAutoSaveFiles()
 
+		a.tray.Update(status)
+
 		if a.win != nil {
 			a.win.Update(status)
 		}
@@ -167,6 +204,8 @@ func (a *App) update(status tsutil.Status) {
 }
 
 func (a *App) init(ctx context.Context) {
+	a.initLogging(slog.LevelInfo, false)
+
 	gtk.Init()
 
 	a.app = adw.NewApplication(metadata.AppID, gio.ApplicationHandlesOpen)
@@ -177,12 +216,13 @@ func (a *App) init(ctx context.Context) {
 
 	var hideWindow bool
 	a.app.AddMainOption("hide-window", 0, glib.OptionFlagNone, glib.OptionArgNone, "Hide window on initial start", "")
+	applyLoggingOptions := a.initLoggingOptions()
 	a.app.ConnectHandleLocalOptions(func(options *glib.VariantDict) int {
 		if options.Contains("hide-window") {
 			hideWindow = true
 		}
 
-		return -1
+		return applyLoggingOptions(options)
 	})
 
 	a.app.ConnectOpen(func(files []gio.Filer, hint string) {
@@ -191,6 +231,11 @@ func (a *App) init(ctx context.Context) {
 
 	a.app.ConnectStartup(func() {
 		a.app.Hold()
+		a.initNotificationActions(ctx)
+
+		adw.StyleManagerGetDefault().NotifyProperty("dark", func() {
+			a.tray.SetIconTheme(a.trayIconTheme())
+		})
 	})
 
 	a.app.ConnectActivate(func() {
@@ -204,18 +249,23 @@ func (a *App) init(ctx context.Context) {
 	a.initSettings(ctx)
 }
 
+// startTS connects to the tailnet, or asks the user to log in if that
+// is necessary. Unlike most methods, it is safe to call from any
+// goroutine.
 func (a *App) startTS(ctx context.Context) error {
 	status := <-a.poller.GetIPN()
 	if status.NeedsAuth() {
-		Confirmation{
-			Heading: "Login Required",
-			Body:    "Open a browser to authenticate with Tailscale?",
-			Accept:  "_Open Browser",
-			Reject:  "_Cancel",
-		}.Show(a, func(accept bool) {
-			if accept {
-				a.app.ActivateAction("login", nil)
-			}
+		glib.IdleAdd(func() {
+			Confirmation{
+				Heading: "Login Required",
+				Body:    "Open a browser to authenticate with Tailscale?",
+				Accept:  "_Open Browser",
+				Reject:  "_Cancel",
+			}.Show(a, func(accept bool) {
+				if accept {
+					a.app.ActivateAction("login", nil)
+				}
+			})
 		})
 		return nil
 	}
@@ -289,10 +339,7 @@ func (a *App) onAppActivate(ctx context.Context) {
 		s := state.Boolean()
 		err := tsutil.SetUseExitNode(ctx, s)
 		if err != nil {
-			slog.Error("failed to set exit node state", "state", s, "err", err)
-			if a.win != nil {
-				a.win.Toast("Failed to toggle exit node")
-			}
+			a.reportError("Failed to toggle exit node", err)
 		}
 	})
 	useExitNodeAction.SetEnabled(false)
@@ -302,14 +349,34 @@ func (a *App) onAppActivate(ctx context.Context) {
 	changeControlServerAction.ConnectActivate(func(p *glib.Variant) { a.showChangeControlServer() })
 	a.app.AddAction(changeControlServerAction)
 
+	addProfileAction := gio.NewSimpleAction("add_profile", nil)
+	addProfileAction.ConnectActivate(func(p *glib.Variant) { a.addProfile(ctx) })
+	a.app.AddAction(addProfileAction)
+
+	logoutAction := gio.NewSimpleAction("logout", nil)
+	logoutAction.ConnectActivate(func(p *glib.Variant) { a.logout(ctx) })
+	a.app.AddAction(logoutAction)
+
+	deleteProfileAction := gio.NewSimpleAction("delete_profile", nil)
+	deleteProfileAction.ConnectActivate(func(p *glib.Variant) { a.deleteProfile(ctx) })
+	a.app.AddAction(deleteProfileAction)
+
 	preferencesAction := gio.NewSimpleAction("preferences", nil)
 	preferencesAction.ConnectActivate(func(p *glib.Variant) { a.showPreferences() })
 	a.app.AddAction(preferencesAction)
 
 	adminDashboardAction := gio.NewSimpleAction("admin_dashboard", nil)
-	adminDashboardAction.ConnectActivate(func(p *glib.Variant) { gtk.NewURILauncher(tsutil.AdminDashboardURL).Launch(ctx, a.window(), nil) })
+	adminDashboardAction.ConnectActivate(func(p *glib.Variant) { a.openAdminConsole(ctx) })
 	a.app.AddAction(adminDashboardAction)
 
+	logsAction := gio.NewSimpleAction("logs", nil)
+	logsAction.ConnectActivate(func(p *glib.Variant) { a.showLogs() })
+	a.app.AddAction(logsAction)
+
+	bugReportAction := gio.NewSimpleAction("bug_report", nil)
+	bugReportAction.ConnectActivate(func(p *glib.Variant) { a.generateBugReport() })
+	a.app.AddAction(bugReportAction)
+
 	aboutAction := gio.NewSimpleAction("about", nil)
 	aboutAction.ConnectActivate(func(p *glib.Variant) { a.showAbout() })
 	a.app.AddAction(aboutAction)
@@ -332,23 +399,18 @@ func (a *App) onAppActivate(ctx context.Context) {
 
 		err := tsutil.StartLogin(ctx)
 		if err != nil {
-			slog.Error("failed to start login", "err", err)
-			if a.win != nil {
-				a.win.Toast("Failed to start login")
-			}
+			a.reportError("Failed to start login", err)
 			return
 		}
 
 		for {
 			select {
 			case <-ctx.Done():
-				if a.win != nil {
-					a.win.Toast("Failed to start login")
-				}
+				a.reportError("Failed to start login", ctx.Err())
 				return
 			case status := <-a.poller.NextIPN():
 				if status.BrowseToURL != "" {
-					gtk.NewURILauncher(status.BrowseToURL).Launch(ctx, a.window(), nil)
+					a.showLoginDialog(status.BrowseToURL)
 					return
 				}
 			}
@@ -356,6 +418,10 @@ func (a *App) onAppActivate(ctx context.Context) {
 	})
 	a.app.AddAction(loginAction)
 
+	loginAuthKeyAction := gio.NewSimpleAction("login_auth_key", nil)
+	loginAuthKeyAction.ConnectActivate(func(p *glib.Variant) { a.showAuthKeyLogin(ctx) })
+	a.app.AddAction(loginAuthKeyAction)
+
 	a.win = NewMainWindow(a)
 	a.win.MainWindow.ConnectCloseRequest(func() bool {
 		a.win = nil
@@ -370,6 +436,47 @@ func (a *App) onAppActivate(ctx context.Context) {
 	})
 }
 
+// initNotificationActions adds the actions that are used by buttons
+// in notifications. Unlike most actions, these need to be available
+// even if the main window has never been opened.
+func (a *App) initNotificationActions(ctx context.Context) {
+	copyClipboardFileAction := gio.NewSimpleAction("copy_clipboard_file", glib.NewVariantType("s"))
+	copyClipboardFileAction.ConnectActivate(func(p *glib.Variant) { a.copyClipboardFile(ctx, p.String()) })
+	a.app.AddAction(copyClipboardFileAction)
+
+	openClipboardLinkAction := gio.NewSimpleAction("open_clipboard_link", glib.NewVariantType("s"))
+	openClipboardLinkAction.ConnectActivate(func(p *glib.Variant) { a.openClipboardLink(ctx, p.String()) })
+	a.app.AddAction(openClipboardLinkAction)
+
+	saveWaitingFileAction := gio.NewSimpleAction("save_waiting_file", glib.NewVariantType("s"))
+	saveWaitingFileAction.ConnectActivate(func(p *glib.Variant) { a.saveToDownloads(ctx, p.String()) })
+	a.app.AddAction(saveWaitingFileAction)
+
+	saveWaitingFileAsAction := gio.NewSimpleAction("save_waiting_file_as", glib.NewVariantType("s"))
+	saveWaitingFileAsAction.ConnectActivate(func(p *glib.Variant) { a.promptSaveFile(p.String()) })
+	a.app.AddAction(saveWaitingFileAsAction)
+
+	deleteWaitingFileAction := gio.NewSimpleAction("delete_waiting_file", glib.NewVariantType("s"))
+	deleteWaitingFileAction.ConnectActivate(func(p *glib.Variant) { a.deleteWaitingFileFromNotification(ctx, p.String()) })
+	a.app.AddAction(deleteWaitingFileAction)
+
+	showWaitingFileAction := gio.NewSimpleAction("show_waiting_file", glib.NewVariantType("s"))
+	showWaitingFileAction.ConnectActivate(func(p *glib.Variant) { a.showWaitingFile(p.String()) })
+	a.app.AddAction(showWaitingFileAction)
+
+	openSavedFileAction := gio.NewSimpleAction("open_saved_file", glib.NewVariantType("s"))
+	openSavedFileAction.ConnectActivate(func(p *glib.Variant) { a.openSavedFile(ctx, p.String()) })
+	a.app.AddAction(openSavedFileAction)
+
+	showSavedFileAction := gio.NewSimpleAction("show_saved_file", glib.NewVariantType("s"))
+	showSavedFileAction.ConnectActivate(func(p *glib.Variant) { a.showSavedFile(ctx, p.String()) })
+	a.app.AddAction(showSavedFileAction)
+
+	recentErrorsAction := gio.NewSimpleAction("recent_errors", nil)
+	recentErrorsAction.ConnectActivate(func(p *glib.Variant) { a.showRecentErrors() })
+	a.app.AddAction(recentErrorsAction)
+}
+
 func (a *App) initTray(ctx context.Context) {
 	if a.tray != nil {
 		err := a.tray.Start(<-a.poller.GetIPN())
@@ -388,21 +495,33 @@ func (a *App) initTray(ctx context.Context) {
 			})
 		},
 
-		OnConnToggle: func() {
+		OnToggleWindow: func() {
 			glib.IdleAdd(func() {
-				ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
-				defer cancel()
+				if a.win != nil && a.win.MainWindow.IsVisible() {
+					a.win.MainWindow.Close()
+					return
+				}
+				if a.app != nil {
+					a.app.Activate()
+				}
+			})
+		},
 
+		OnConnToggle: func() {
+			glib.IdleAdd(func() {
 				f := a.stopTS
 				if !a.online {
 					f = a.startTS
 				}
 
-				err := f(ctx)
-				if err != nil {
-					slog.Error("set Tailscale status from tray", "err", err)
-					return
-				}
+				asyncAction{
+					Run: f,
+					Done: func(err error) {
+						if err != nil {
+							a.reportError("Failed to change Tailscale status", err)
+						}
+					},
+				}.Start(a)
 			})
 		},
 
@@ -415,8 +534,7 @@ func (a *App) initTray(ctx context.Context) {
 				toggle := !s.ExitNodeActive()
 				err := tsutil.SetUseExitNode(ctx, toggle)
 				if err != nil {
-					a.notify("Toggle exit node", err.Error())
-					slog.Error("toggle exit node from tray", "err", err)
+					a.reportError("Failed to toggle exit node", err)
 					return
 				}
 
@@ -442,11 +560,20 @@ func (a *App) initTray(ctx context.Context) {
 			})
 		},
 
+		OnSendClipboard: func(peerID tailcfg.StableNodeID, peerName string) {
+			glib.IdleAdd(func() {
+				a.sendClipboard(ctx, peerID, peerName)
+			})
+		},
+
 		OnQuit: func() {
 			a.Quit()
 		},
 	}
 
+	a.tray.SetIconTheme(a.trayIconTheme())
+	a.tray.SetClickActions(a.trayClickActions())
+
 	err := a.tray.Start(<-a.poller.GetIPN())
 	if err != nil {
 		slog.Error("failed to start tray icon", "err", err)
@@ -481,5 +608,9 @@ func (a *App) Run(ctx context.Context) {
 	}
 	go a.poller.Run(ctx)
 
+	a.loadNetCheckHistory()
+	stopLinkChanges := tsutil.WatchLinkChanges(func() { glib.IdleAdd(a.onLinkChange) })
+	defer stopLinkChanges()
+
 	a.app.Run(os.Args)
 }
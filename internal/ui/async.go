@@ -0,0 +1,151 @@
+package ui
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// asyncTimeout is the timeout used for an asyncAction that doesn't
+// specify one.
+const asyncTimeout = 30 * time.Second
+
+// asyncAction is a potentially slow call, such as one to the
+// LocalAPI, that is run on its own goroutine so that it doesn't block
+// the main loop.
+type asyncAction struct {
+	// Run performs the action. It is called on a new goroutine and
+	// must not touch the UI.
+	Run func(ctx context.Context) error
+
+	// Busy, if not nil, is called on the main loop with true when the
+	// action starts and with false when it's done.
+	Busy func(busy bool)
+
+	// Done, if not nil, is called on the main loop with the error
+	// returned by Run after Busy has been called with false.
+	Done func(err error)
+
+	// Timeout is the longest that Run is allowed to take. If it is
+	// zero, asyncTimeout is used.
+	Timeout time.Duration
+}
+
+// Start starts the action and returns a function that cancels it. It
+// must be called from the main loop.
+func (action asyncAction) Start(a *App) (cancel func()) {
+	ctx, cancel := context.WithTimeout(context.Background(), cmp.Or(action.Timeout, asyncTimeout))
+
+	if action.Busy != nil {
+		action.Busy(true)
+	}
+	a.spin()
+
+	go func() {
+		defer cancel()
+		defer a.stopSpin()
+
+		err := action.Run(ctx)
+		glib.IdleAdd(func() {
+			if action.Busy != nil {
+				action.Busy(false)
+			}
+			if action.Done != nil {
+				action.Done(err)
+			}
+		})
+	}()
+
+	return cancel
+}
+
+// busyIndicator is a spinner with a button to cancel whatever is
+// keeping it busy. It is hidden when not busy.
+type busyIndicator struct {
+	*gtk.Box
+	cancel func()
+}
+
+func newBusyIndicator() *busyIndicator {
+	b := busyIndicator{Box: gtk.NewBox(gtk.OrientationHorizontal, 6)}
+	b.SetVAlign(gtk.AlignCenter)
+	b.SetVisible(false)
+
+	b.Append(adw.NewSpinner())
+
+	cancel := gtk.NewButtonFromIconName("process-stop-symbolic")
+	cancel.AddCSSClass("flat")
+	cancel.AddCSSClass("circular")
+	cancel.SetTooltipText("Cancel")
+	cancel.ConnectClicked(func() {
+		if b.cancel != nil {
+			b.cancel()
+		}
+	})
+	b.Append(cancel)
+
+	return &b
+}
+
+// SetBusy shows or hides the indicator. While it is shown, its cancel
+// button calls cancel.
+func (b *busyIndicator) SetBusy(busy bool, cancel func()) {
+	b.SetVisible(busy)
+	b.cancel = cancel
+	if !busy {
+		b.cancel = nil
+	}
+}
+
+// Start starts action, showing the indicator and disabling control
+// until it's done.
+func (b *busyIndicator) Start(a *App, control gtk.Widgetter, action asyncAction) {
+	busy := action.Busy
+	action.Busy = func(v bool) {
+		b.SetBusy(v, nil)
+		gtk.BaseWidget(control).SetSensitive(!v)
+		if busy != nil {
+			busy(v)
+		}
+	}
+	b.cancel = action.Start(a)
+}
+
+// connectAsyncSwitch makes toggling sw call set asynchronously. While
+// set is running, sw is disabled and busy is shown. If set fails, sw
+// is switched back and, unless it was canceled, the error is reported
+// with msg. Otherwise, its state is left to be updated along with the
+// rest of the UI when the new status arrives.
+func (a *App) connectAsyncSwitch(sw *gtk.Switch, busy *busyIndicator, msg string, set func(ctx context.Context, s bool) error) {
+	sw.ConnectStateSet(func(s bool) bool {
+		if s == sw.State() {
+			return false
+		}
+
+		busy.Start(a, sw, asyncAction{
+			Run: func(ctx context.Context) error { return set(ctx, s) },
+			Done: func(err error) {
+				if err != nil {
+					if !errors.Is(err, context.Canceled) {
+						a.reportError(msg, err)
+					}
+					sw.SetActive(!s)
+				}
+			},
+		})
+		return true
+	})
+}
+
+// connectAsyncSwitchRow is like connectAsyncSwitch for the switch in
+// row, showing the busy indicator in the row.
+func (a *App) connectAsyncSwitchRow(row *adw.SwitchRow, msg string, set func(ctx context.Context, s bool) error) {
+	busy := newBusyIndicator()
+	row.AddSuffix(busy)
+	a.connectAsyncSwitch(row.ActivatableWidget().(*gtk.Switch), busy, msg, set)
+}
@@ -0,0 +1,216 @@
+package ui
+
+import (
+	"cmp"
+	_ "embed"
+	"fmt"
+	"log/slog"
+	"mime"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"deedles.dev/trayscale/internal/autosave"
+	"deedles.dev/trayscale/internal/gutil"
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+//go:embed autosaverule.ui
+var autoSaveRuleXML string
+
+type AutoSaveRuleEditor struct {
+	Group         *adw.PreferencesGroup
+	NameRow       *adw.EntryRow
+	DestRow       *adw.EntryRow
+	SendersRow    *adw.EntryRow
+	ExtensionsRow *adw.EntryRow
+	MIMETypesRow  *adw.EntryRow
+	MinSizeRow    *adw.EntryRow
+	MaxSizeRow    *adw.EntryRow
+}
+
+func NewAutoSaveRuleEditor(rule autosave.Rule) *AutoSaveRuleEditor {
+	var editor AutoSaveRuleEditor
+	gutil.FillFromUI(&editor, autoSaveRuleXML)
+
+	editor.NameRow.SetText(rule.Name)
+	editor.DestRow.SetText(rule.Dest)
+	editor.SendersRow.SetText(strings.Join(rule.Senders, ", "))
+	editor.ExtensionsRow.SetText(strings.Join(rule.Extensions, ", "))
+	editor.MIMETypesRow.SetText(strings.Join(rule.MIMETypes, ", "))
+	editor.MinSizeRow.SetText(autosave.FormatSize(rule.MinSize))
+	editor.MaxSizeRow.SetText(autosave.FormatSize(rule.MaxSize))
+
+	return &editor
+}
+
+// Rule returns the rule described by the editor's current contents.
+func (editor *AutoSaveRuleEditor) Rule() (autosave.Rule, error) {
+	rule := autosave.Rule{
+		Name:       strings.TrimSpace(editor.NameRow.Text()),
+		Dest:       strings.TrimSpace(editor.DestRow.Text()),
+		Senders:    splitList(editor.SendersRow.Text()),
+		Extensions: splitList(editor.ExtensionsRow.Text()),
+		MIMETypes:  splitList(editor.MIMETypesRow.Text()),
+	}
+
+	var err error
+	rule.MinSize, err = autosave.ParseSize(editor.MinSizeRow.Text())
+	if err != nil {
+		return rule, err
+	}
+	rule.MaxSize, err = autosave.ParseSize(editor.MaxSizeRow.Text())
+	if err != nil {
+		return rule, err
+	}
+	if rule.MaxSize > 0 && rule.MinSize > rule.MaxSize {
+		return rule, fmt.Errorf("minimum size is larger than maximum size")
+	}
+
+	// Check the template against a representative file so that typos
+	// are caught now instead of when a file arrives.
+	_, err = autosave.Expand(rule.Dest, autosave.File{Name: "example.txt", MIMEType: "text/plain", Sender: "example"}, time.Now(), "/home")
+	if err != nil {
+		return rule, err
+	}
+
+	return rule, nil
+}
+
+// autoSaveRules returns the auto-save routing rules from settings.
+func (a *App) autoSaveRules() []autosave.Rule {
+	if a.settings == nil {
+		return nil
+	}
+
+	rules, err := autosave.ParseRules(a.settings.String("taildrop-auto-save-rules"))
+	if err != nil {
+		slog.Error("parse taildrop auto-save rules", "err", err)
+		return nil
+	}
+	return rules
+}
+
+// autoSaveDest returns the directory that the waiting file f should
+// be auto-saved into.
+func (a *App) autoSaveDest(rules []autosave.Rule, dir string, f autosave.File) (string, error) {
+	if f.MIMEType == "" {
+		f.MIMEType = mime.TypeByExtension(filepath.Ext(f.Name))
+	}
+
+	home, _ := os.UserHomeDir()
+	return autosave.Destination(rules, dir, f, time.Now(), home)
+}
+
+func describeRule(rule autosave.Rule) string {
+	var conds []string
+	if len(rule.Senders) != 0 {
+		conds = append(conds, "from "+strings.Join(rule.Senders, ", "))
+	}
+	if len(rule.Extensions) != 0 {
+		conds = append(conds, "extension "+strings.Join(rule.Extensions, ", "))
+	}
+	if len(rule.MIMETypes) != 0 {
+		conds = append(conds, "type "+strings.Join(rule.MIMETypes, ", "))
+	}
+	if rule.MinSize > 0 {
+		conds = append(conds, "at least "+autosave.FormatSize(rule.MinSize))
+	}
+	if rule.MaxSize > 0 {
+		conds = append(conds, "at most "+autosave.FormatSize(rule.MaxSize))
+	}
+	if len(conds) == 0 {
+		return "All files"
+	}
+	return strings.Join(conds, "; ")
+}
+
+// initAutoSaveRules populates the auto-save rules group of the
+// preferences dialog and keeps it in sync with settings.
+func (a *App) initAutoSaveRules(dialog *PreferencesDialog) {
+	var rows []*adw.ActionRow
+
+	var refresh func()
+	save := func(rules []autosave.Rule) {
+		a.settings.SetString("taildrop-auto-save-rules", autosave.FormatRules(rules))
+		refresh()
+	}
+
+	refresh = func() {
+		for _, row := range rows {
+			dialog.TaildropRulesGroup.Remove(row)
+		}
+		rows = rows[:0]
+
+		rules := a.autoSaveRules()
+		for i, rule := range rules {
+			row := adw.NewActionRow()
+			row.SetTitle(cmp.Or(rule.Name, rule.Dest))
+			row.SetSubtitle(describeRule(rule) + " → " + rule.Dest)
+
+			edit := gtk.NewButtonFromIconName("document-edit-symbolic")
+			edit.SetTooltipText("Edit rule")
+			edit.SetVAlign(gtk.AlignCenter)
+			edit.AddCSSClass("flat")
+			edit.ConnectClicked(func() {
+				a.editAutoSaveRule(dialog, rule, func(rule autosave.Rule) {
+					rules[i] = rule
+					save(rules)
+				})
+			})
+			row.AddSuffix(edit)
+
+			remove := gtk.NewButtonFromIconName("user-trash-symbolic")
+			remove.SetTooltipText("Remove rule")
+			remove.SetVAlign(gtk.AlignCenter)
+			remove.AddCSSClass("flat")
+			remove.ConnectClicked(func() {
+				save(slices.Delete(rules, i, i+1))
+			})
+			row.AddSuffix(remove)
+
+			dialog.TaildropRulesGroup.Add(row)
+			rows = append(rows, row)
+		}
+	}
+	refresh()
+
+	dialog.TaildropAddRuleButton.ConnectClicked(func() {
+		a.editAutoSaveRule(dialog, autosave.Rule{Dest: "~/Taildrop/{type}"}, func(rule autosave.Rule) {
+			save(append(a.autoSaveRules(), rule))
+		})
+	})
+}
+
+// editAutoSaveRule shows a dialog for editing rule and calls done
+// with the result if the user saves it.
+func (a *App) editAutoSaveRule(parent *PreferencesDialog, rule autosave.Rule, done func(autosave.Rule)) {
+	editor := NewAutoSaveRuleEditor(rule)
+
+	dialog := adw.NewAlertDialog("Auto-save Rule", "Files matching every filled-in condition are saved to the folder. Available placeholders are {sender}, {date}, {year}, {month}, {day}, {ext}, and {type}. Tailscale doesn't report who sent a file yet, so sender conditions don't match any files and {sender} is always \"unknown\" for now.")
+	dialog.SetExtraChild(editor.Group)
+	dialog.AddResponse("cancel", "_Cancel")
+	dialog.SetCloseResponse("cancel")
+	dialog.AddResponse("save", "_Save")
+	dialog.SetResponseAppearance("save", adw.ResponseSuggested)
+	dialog.SetDefaultResponse("save")
+
+	dialog.ConnectResponse(func(response string) {
+		if response != "save" {
+			return
+		}
+
+		rule, err := editor.Rule()
+		if err != nil {
+			parent.PreferencesDialog.AddToast(adw.NewToast(fmt.Sprintf("Invalid rule: %v", err)))
+			a.editAutoSaveRule(parent, rule, done)
+			return
+		}
+		done(rule)
+	})
+
+	dialog.Present(parent.PreferencesDialog)
+}
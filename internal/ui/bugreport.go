@@ -0,0 +1,254 @@
+package ui
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"log/slog"
+	"maps"
+	"net/netip"
+	"os"
+	"slices"
+	"time"
+
+	"deedles.dev/trayscale/internal/bugreport"
+	"deedles.dev/trayscale/internal/gutil"
+	"deedles.dev/trayscale/internal/metadata"
+	"deedles.dev/trayscale/internal/redact"
+	"deedles.dev/trayscale/internal/tsutil"
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"tailscale.com/ipn"
+	"tailscale.com/net/tsaddr"
+	"tailscale.com/tailcfg"
+	"tailscale.com/types/netmap"
+	"tailscale.com/types/views"
+)
+
+// bugReportTimeout is how long collecting the information for a bug
+// report and saving it may take.
+const bugReportTimeout = time.Minute
+
+// generateBugReport asks the user whether to redact a bug report and
+// where to save it and then generates it. In private mode, bug reports
+// are always redacted.
+func (a *App) generateBugReport() {
+	redactRow := adw.NewSwitchRow()
+	redactRow.SetTitle("Redact Private Information")
+	redactRow.SetSubtitle("Replace names, addresses, and paths with placeholders")
+	redactRow.SetActive(true)
+	redactRow.SetSensitive(!metadata.Private)
+
+	list := gtk.NewListBox()
+	list.AddCSSClass("boxed-list")
+	list.SetSelectionMode(gtk.SelectionNone)
+	list.Append(redactRow)
+
+	Confirmation{
+		Heading: "Generate Bug Report?",
+		Body:    "The bug report includes the version of Trayscale, recent logs and errors, the Tailscale status, the latest network check, and the preferences. Check its contents before attaching it to an issue.",
+		Accept:  "_Save",
+		Reject:  "_Cancel",
+		Extra:   func() gtk.Widgetter { return list },
+	}.Show(a, func(accept bool) {
+		if accept {
+			a.saveBugReport(metadata.Private || redactRow.Active())
+		}
+	})
+}
+
+// saveBugReport asks the user where to save a bug report and then
+// collects the information for it in the background and saves it.
+func (a *App) saveBugReport(redacted bool) {
+	version, ok := metadata.Version()
+	if !ok {
+		version = "unknown"
+	}
+
+	bundle := bugreport.Bundle{
+		Time:            time.Now(),
+		Version:         version,
+		NetCheckHistory: slices.Clone(a.netcheckHistory),
+		Preferences:     a.preferenceValues(),
+		Logs:            a.logs.Entries(),
+		Errors:          a.errors.All(),
+	}
+	if a.netReport.Report != nil {
+		report := a.netReport
+		bundle.NetCheck = &report
+	}
+
+	fd := gtk.NewFileDialog()
+	fd.SetModal(true)
+	fd.SetInitialName(bundle.FileName())
+	fd.Save(context.TODO(), a.window(), func(res gio.AsyncResulter) {
+		f, err := fd.SaveFinish(res)
+		if err != nil {
+			if !gutil.ErrHasCode(err, int(gtk.DialogErrorDismissed)) {
+				a.reportError("Failed to save bug report", err)
+			}
+			return
+		}
+
+		asyncAction{
+			Timeout: bugReportTimeout,
+			Run: func(ctx context.Context) error {
+				var r *redact.Redactor
+				if redacted {
+					home, _ := os.UserHomeDir()
+					r = redact.New(home)
+				}
+
+				select {
+				case status := <-a.poller.GetIPN():
+					s := newBugReportStatus(status, r)
+					bundle.Status = &s
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+
+				bundle.Marker, bundle.MarkerErr = tsutil.BugReport(ctx, "Trayscale bug report")
+				if bundle.MarkerErr != nil {
+					slog.Warn("get tailscaled bug report marker", "err", bundle.MarkerErr)
+				}
+
+				var buf bytes.Buffer
+				err := bundle.Write(&buf, r)
+				if err != nil {
+					return err
+				}
+				return replaceFile(ctx, f, bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			},
+			Done: func(err error) {
+				if err != nil {
+					a.reportError("Failed to generate bug report", err)
+					return
+				}
+				a.toast("Saved bug report")
+			},
+		}.Start(a)
+	})
+}
+
+// preferenceValues returns the current values of all of the settings.
+func (a *App) preferenceValues() map[string]any {
+	if a.settings == nil {
+		return nil
+	}
+
+	schema := gio.SettingsSchemaSourceGetDefault().Lookup(metadata.AppID, true)
+	if schema == nil {
+		return nil
+	}
+
+	prefs := make(map[string]any)
+	for _, key := range schema.ListKeys() {
+		prefs[key] = variantValue(a.settings.Value(key))
+	}
+	return prefs
+}
+
+// variantValue converts the types of values used by the settings to
+// their Go equivalents. Values of other types are formatted as GVariant
+// text.
+func variantValue(v *glib.Variant) any {
+	switch v.TypeString() {
+	case "b":
+		return v.Boolean()
+	case "s":
+		return v.String()
+	case "as":
+		return v.Strv()
+	case "u":
+		return v.Uint32()
+	case "d":
+		return v.Double()
+	default:
+		return v.Print(false)
+	}
+}
+
+// newBugReportStatus returns a snapshot of s for a bug report with
+// private information replaced by r. If r is nil, nothing is replaced.
+func newBugReportStatus(s *tsutil.IPNStatus, r *redact.Redactor) bugreport.Status {
+	status := bugreport.Status{
+		State: s.State.String(),
+		Peers: []bugreport.Node{},
+	}
+	if s.Engine != nil {
+		status.LiveDERPs = s.Engine.LiveDERPs
+		status.LivePeers = s.Engine.NumLive
+	}
+
+	exitNode := s.ExitNode()
+
+	// The self node is redacted first so that it gets the first
+	// placeholders.
+	if s.NetMap != nil {
+		status.Tailnet = r.Domain(s.NetMap.DomainName())
+		if s.NetMap.SelfNode.Valid() {
+			self := newBugReportNode(s.NetMap, s.NetMap.SelfNode, r)
+			status.Self = &self
+		}
+	}
+
+	for _, peer := range slices.SortedFunc(maps.Values(s.Peers), tsutil.ComparePeers) {
+		node := newBugReportNode(s.NetMap, peer, r)
+		node.ExitNode = exitNode.Valid() && (peer.StableID() == exitNode.StableID())
+		status.Peers = append(status.Peers, node)
+	}
+
+	if s.Prefs.Valid() {
+		status.Prefs = &bugreport.Prefs{
+			ControlURL:             r.URL(cmp.Or(s.Prefs.ControlURL(), ipn.DefaultControlURL)),
+			WantRunning:            s.Prefs.WantRunning(),
+			RouteAll:               s.Prefs.RouteAll(),
+			CorpDNS:                s.Prefs.CorpDNS(),
+			ShieldsUp:              s.Prefs.ShieldsUp(),
+			ExitNodeAllowLANAccess: s.Prefs.ExitNodeAllowLANAccess(),
+			AdvertiseRoutes:        redactPrefixes(s.Prefs.AdvertiseRoutes(), r),
+		}
+		if exitNode.Valid() {
+			status.Prefs.ExitNode = r.DNSName(exitNode.Name())
+		}
+	}
+
+	return status
+}
+
+func newBugReportNode(nm *netmap.NetworkMap, n tailcfg.NodeView, r *redact.Redactor) bugreport.Node {
+	node := bugreport.Node{
+		Name:           r.DNSName(n.Name()),
+		Addresses:      redactPrefixes(n.Addresses(), r),
+		PrimaryRoutes:  redactPrefixes(n.PrimaryRoutes(), r),
+		HomeDERP:       n.HomeDERP(),
+		ExitNodeOption: tsaddr.ContainsExitRoutes(n.AllowedIPs()),
+		Mullvad:        tsutil.IsMullvad(n),
+	}
+	if n.Hostinfo().Valid() {
+		node.Hostname = r.Name("node", n.Hostinfo().Hostname())
+		node.OS = n.Hostinfo().OS()
+	}
+	if online, ok := n.Online().GetOk(); ok {
+		node.Online = &online
+	}
+	for _, tag := range n.Tags().All() {
+		node.Tags = append(node.Tags, r.Name("tag:tag", tag))
+	}
+	if nm != nil {
+		if user, ok := nm.UserProfiles[n.User()]; ok {
+			node.User = r.Email(user.LoginName())
+		}
+	}
+	return node
+}
+
+func redactPrefixes(prefixes views.Slice[netip.Prefix], r *redact.Redactor) []netip.Prefix {
+	var s []netip.Prefix
+	for _, p := range prefixes.All() {
+		s = append(s, r.Prefix(p))
+	}
+	return s
+}
@@ -0,0 +1,209 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"deedles.dev/trayscale/internal/clipfile"
+	"deedles.dev/trayscale/internal/tsutil"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/inhies/go-bytesize"
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
+)
+
+// maxClipboardFileSize is the largest incoming clipboard file that
+// will be read into memory to be copied to the clipboard.
+const maxClipboardFileSize = 32 * 1024 * 1024
+
+// sendClipboard sends the current contents of the clipboard to a peer
+// via Taildrop. Images are sent as PNG files and anything else is sent
+// as text.
+func (a *App) sendClipboard(ctx context.Context, peerID tailcfg.StableNodeID, peerName string) {
+	clipboard := gdk.DisplayGetDefault().Clipboard()
+
+	if clipboard.Formats().ContainGType(gdk.GTypeTexture) {
+		clipboard.ReadTextureAsync(ctx, func(res gio.AsyncResulter) {
+			texture, err := clipboard.ReadTextureFinish(res)
+			if err == nil && texture == nil {
+				err = errors.New("no image in clipboard")
+			}
+			if err != nil {
+				a.reportError("Failed to read image from clipboard", err)
+				return
+			}
+
+			data := gdk.BaseTexture(texture).SaveToPNGBytes().Data()
+			go a.pushClipboard(ctx, peerID, peerName, clipfile.Image, data)
+		})
+		return
+	}
+
+	clipboard.ReadTextAsync(ctx, func(res gio.AsyncResulter) {
+		text, err := clipboard.ReadTextFinish(res)
+		if err != nil {
+			a.reportError("Failed to read text from clipboard", err)
+			return
+		}
+		if text == "" {
+			a.notify("Taildrop", "Nothing to send, the clipboard is empty")
+			return
+		}
+
+		go a.pushClipboard(ctx, peerID, peerName, clipfile.Text, []byte(text))
+	})
+}
+
+func (a *App) pushClipboard(ctx context.Context, peerID tailcfg.StableNodeID, peerName string, kind clipfile.Kind, data []byte) {
+	a.spin()
+	defer a.stopSpin()
+
+	name := clipfile.Name(kind, time.Now())
+	slog := slog.With("peer", peerID, "name", name)
+	slog.Info("starting clipboard push")
+
+	err := tsutil.PushFile(ctx, peerID, int64(len(data)), name, bytes.NewReader(data))
+	if err != nil {
+		a.reportError(fmt.Sprintf("Failed to send clipboard to %v", peerName), err)
+		return
+	}
+
+	slog.Info("done pushing clipboard")
+	glib.IdleAdd(func() { a.notify("Taildrop", fmt.Sprintf("Sent clipboard to %v", peerName)) })
+}
+
+// readWaitingFile reads the entire contents of a waiting file without
+// deleting it. It fails if the file is larger than limit.
+func readWaitingFile(ctx context.Context, name string, limit int64) ([]byte, error) {
+	r, size, err := tsutil.GetWaitingFile(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("get file: %w", err)
+	}
+	defer r.Close()
+
+	if size > limit {
+		return nil, fmt.Errorf("file is too large (%v)", bytesize.ByteSize(size))
+	}
+
+	return io.ReadAll(io.LimitReader(r, limit))
+}
+
+// notifyClipboardFile sends a notification for an incoming clipboard
+// file that allows the user to copy it to the clipboard or, if it
+// contains just a URL, open it directly.
+func (a *App) notifyClipboardFile(ctx context.Context, file apitype.WaitingFile, kind clipfile.Kind) {
+	body := fmt.Sprintf("Image (%v)", bytesize.ByteSize(file.Size))
+	var link bool
+	if kind == clipfile.Text {
+		data, err := readWaitingFile(ctx, file.Name, maxClipboardFileSize)
+		if err != nil {
+			slog.Error("read incoming clipboard file", "name", file.Name, "err", err)
+			return
+		}
+
+		text := string(data)
+		_, link = clipfile.URL(text)
+		body = clipboardSnippet(text)
+	}
+
+	glib.IdleAdd(func() {
+		target := glib.NewVariantString(file.Name)
+
+		n := newNotification("Incoming Clipboard", body)
+		n.AddButtonWithTarget("Copy to Clipboard", "app.copy_clipboard_file", target)
+		if link {
+			n.AddButtonWithTarget("Open Link", "app.open_clipboard_link", target)
+		}
+		a.app.SendNotification(incomingFileNotificationID(file.Name), n)
+	})
+}
+
+// clipboardSnippet returns a short, single-line summary of text
+// suitable for a notification body.
+func clipboardSnippet(text string) string {
+	const maxLen = 80
+
+	text = strings.TrimSpace(text)
+	line, _, more := strings.Cut(text, "\n")
+	if r := []rune(line); len(r) > maxLen {
+		return string(r[:maxLen]) + "…"
+	}
+	if more {
+		return line + " …"
+	}
+	return line
+}
+
+// copyClipboardFile copies the contents of the incoming clipboard file
+// name into the local clipboard and then deletes it.
+func (a *App) copyClipboardFile(ctx context.Context, name string) {
+	kind, ok := clipfile.Parse(name)
+	if !ok {
+		return
+	}
+
+	go func() {
+		data, err := readWaitingFile(ctx, name, maxClipboardFileSize)
+		if err != nil {
+			a.reportError("Failed to read incoming clipboard", err)
+			return
+		}
+
+		glib.IdleAdd(func() {
+			switch kind {
+			case clipfile.Text:
+				a.clip(glib.NewValue(string(data)))
+			case clipfile.Image:
+				texture, err := gdk.NewTextureFromBytes(glib.NewBytes(data))
+				if err != nil {
+					a.reportError("Failed to decode incoming image", err)
+					return
+				}
+				gdk.DisplayGetDefault().Clipboard().SetTexture(texture)
+			}
+
+			go a.deleteWaitingFile(ctx, name)
+		})
+	}()
+}
+
+// openClipboardLink opens the URL contained in the incoming clipboard
+// file name and then deletes it.
+func (a *App) openClipboardLink(ctx context.Context, name string) {
+	go func() {
+		data, err := readWaitingFile(ctx, name, maxClipboardFileSize)
+		if err != nil {
+			a.reportError("Failed to read incoming link", err)
+			return
+		}
+
+		u, ok := clipfile.URL(string(data))
+		if !ok {
+			return
+		}
+
+		glib.IdleAdd(func() {
+			gtk.NewURILauncher(u.String()).Launch(ctx, a.window(), nil)
+			go a.deleteWaitingFile(ctx, name)
+		})
+	}()
+}
+
+func (a *App) deleteWaitingFile(ctx context.Context, name string) error {
+	err := tsutil.DeleteWaitingFile(ctx, name)
+	if err != nil {
+		slog.Error("delete file", "name", name, "err", err)
+		return err
+	}
+	<-a.poller.Poll()
+	return nil
+}
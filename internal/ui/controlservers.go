@@ -0,0 +1,340 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"deedles.dev/trayscale/internal/controlserver"
+	"deedles.dev/trayscale/internal/gutil"
+	"deedles.dev/trayscale/internal/tsutil"
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// controlServerProbeTimeout is how long to wait for a control server
+// to respond before deciding that it is unreachable.
+const controlServerProbeTimeout = 10 * time.Second
+
+// controlServers returns the saved control servers, not including
+// controlserver.Default.
+func (a *App) controlServers() []controlserver.Server {
+	if a.settings == nil {
+		return nil
+	}
+
+	servers, err := controlserver.Parse(a.settings.String("control-servers"))
+	if err != nil {
+		slog.Error("parse saved control servers", "err", err)
+		return nil
+	}
+	return servers
+}
+
+func (a *App) setControlServers(servers []controlserver.Server) {
+	if a.settings == nil {
+		return
+	}
+	a.settings.SetString("control-servers", controlserver.Format(servers))
+}
+
+// controlServerName returns a name for the control server at
+// controlURL, using the name that it was saved with if it was.
+func (a *App) controlServerName(controlURL string) string {
+	if server, ok := controlserver.Find(a.controlServers(), controlURL); ok {
+		return server.Name
+	}
+	if u, err := url.Parse(controlURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return controlURL
+}
+
+// adminURL returns the URL of the admin console for the control
+// server that the daemon is using, if one is known.
+func (a *App) adminURL(status *tsutil.IPNStatus) (string, bool) {
+	if !status.Prefs.Valid() {
+		return "", false
+	}
+	return controlserver.AdminURL(a.controlServers(), status.Prefs.ControlURL())
+}
+
+func (a *App) openAdminConsole(ctx context.Context) {
+	status := <-a.poller.GetIPN()
+	adminURL, ok := a.adminURL(status)
+	if !ok {
+		a.toast("No admin console is known for this control server")
+		return
+	}
+	gtk.NewURILauncher(adminURL).Launch(ctx, a.window(), nil)
+}
+
+func (a *App) toast(msg string) {
+	if a.win != nil {
+		a.win.Toast(msg)
+	}
+}
+
+func (a *App) showChangeControlServer() {
+	status := <-a.poller.GetIPN()
+	current := status.Prefs.ControlURL()
+
+	selected, ok := controlserver.Find(a.controlServers(), current)
+	if !ok {
+		selected = controlserver.Server{Name: "Current Server", URL: current}
+	}
+
+	list := gtk.NewListBox()
+	list.AddCSSClass("boxed-list")
+	list.SetSelectionMode(gtk.SelectionNone)
+
+	var rows []gtk.Widgetter
+	var refresh func()
+	refresh = func() {
+		for _, row := range rows {
+			list.Remove(row)
+		}
+		rows = rows[:0]
+
+		saved := a.controlServers()
+		servers := append([]controlserver.Server{controlserver.Default}, saved...)
+		if _, ok := controlserver.Find(saved, current); !ok {
+			servers = append(servers, controlserver.Server{Name: "Current Server", URL: current})
+		}
+
+		var group *gtk.CheckButton
+		for _, server := range servers {
+			check := gtk.NewCheckButton()
+			check.SetGroup(group)
+			if group == nil {
+				group = check
+			}
+			check.SetActive(controlserver.SameURL(server.URL, selected.URL))
+			check.ConnectToggled(func() {
+				if check.Active() {
+					selected = server
+				}
+			})
+
+			row := adw.NewActionRow()
+			row.SetUseMarkup(false)
+			row.SetTitle(server.Name)
+			row.SetSubtitle(server.URL)
+			row.AddPrefix(check)
+			row.SetActivatableWidget(check)
+
+			i := slices.Index(saved, server)
+			if i >= 0 {
+				edit := gtk.NewButtonFromIconName("document-edit-symbolic")
+				edit.SetTooltipText("Set admin console")
+				edit.SetVAlign(gtk.AlignCenter)
+				edit.AddCSSClass("flat")
+				edit.ConnectClicked(func() {
+					a.editControlServerAdminURL(saved, i, refresh)
+				})
+				row.AddSuffix(edit)
+
+				remove := gtk.NewButtonFromIconName("user-trash-symbolic")
+				remove.SetTooltipText("Remove server")
+				remove.SetVAlign(gtk.AlignCenter)
+				remove.AddCSSClass("flat")
+				remove.ConnectClicked(func() {
+					if selected == server {
+						selected = controlserver.Default
+					}
+					a.setControlServers(slices.Delete(saved, i, i+1))
+					refresh()
+				})
+				row.AddSuffix(remove)
+			}
+
+			list.Append(row)
+			rows = append(rows, row)
+		}
+
+		add := adw.NewButtonRow()
+		add.SetTitle("Add Server")
+		add.SetStartIconName("list-add-symbolic")
+		add.ConnectActivated(func() {
+			a.addControlServer(func(server controlserver.Server) {
+				selected = server
+				refresh()
+			})
+		})
+		list.Append(add)
+		rows = append(rows, add)
+	}
+	refresh()
+
+	dialog := adw.NewAlertDialog("Control Server", "Select the server that coordinates the tailnet.")
+	dialog.SetExtraChild(list)
+	dialog.AddResponse("cancel", "_Cancel")
+	dialog.SetCloseResponse("cancel")
+	dialog.AddResponse("connect", "_Connect")
+	dialog.SetResponseAppearance("connect", adw.ResponseSuggested)
+	dialog.SetDefaultResponse("connect")
+	dialog.ConnectResponse(func(response string) {
+		if (response != "connect") || controlserver.SameURL(selected.URL, current) {
+			return
+		}
+		go a.switchControlServer(selected)
+	})
+
+	dialog.Present(gutil.PointerToWidgetter(a.window()))
+}
+
+// addControlServer prompts for a new control server and then calls
+// added with it once it has been checked and saved.
+func (a *App) addControlServer(added func(controlserver.Server)) {
+	nameRow := adw.NewEntryRow()
+	nameRow.SetTitle("Name (optional)")
+
+	adminRow := adw.NewEntryRow()
+	adminRow.SetTitle("Admin Console URL (optional)")
+	adminRow.SetInputPurpose(gtk.InputPurposeURL)
+
+	Prompt{
+		Heading:     "Add Control Server",
+		Body:        "The server is checked to make sure that it is reachable before it is saved.",
+		Placeholder: "https://headscale.example.com",
+		Purpose:     gtk.InputPurposeURL,
+		Responses: []PromptResponse{
+			{ID: "cancel", Label: "_Cancel"},
+			{ID: "add", Label: "_Add", Appearance: adw.ResponseSuggested, Default: true},
+		},
+		Extra: func() gtk.Widgetter {
+			list := gtk.NewListBox()
+			list.AddCSSClass("boxed-list")
+			list.SetSelectionMode(gtk.SelectionNone)
+			list.Append(nameRow)
+			list.Append(adminRow)
+			return list
+		},
+	}.Show(a, "", func(response, val string) {
+		if response != "add" {
+			return
+		}
+
+		controlURL, err := controlserver.ValidateURL(val)
+		if err != nil {
+			a.reportError("Invalid control server URL", err)
+			return
+		}
+		if server, ok := controlserver.Find(a.controlServers(), controlURL); ok {
+			a.toast(fmt.Sprintf("%v is already saved", server.Name))
+			return
+		}
+
+		adminURL, err := validateAdminURL(adminRow.Text())
+		if err != nil {
+			a.reportError("Invalid admin console URL", err)
+			return
+		}
+
+		server := controlserver.Server{
+			Name:     nameRow.Text(),
+			URL:      controlURL,
+			AdminURL: adminURL,
+		}
+		if server.Name == "" {
+			u, _ := url.Parse(controlURL)
+			server.Name = u.Host
+		}
+
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), controlServerProbeTimeout)
+			defer cancel()
+
+			err := controlserver.Probe(ctx, nil, server.URL)
+			glib.IdleAdd(func() {
+				if err != nil {
+					a.reportError("Control server is not reachable", err)
+					return
+				}
+
+				a.setControlServers(append(a.controlServers(), server))
+				added(server)
+			})
+		}()
+	})
+}
+
+// validateAdminURL checks that an admin console URL entered by the
+// user is a web URL. An empty URL means that there is no console.
+func validateAdminURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid admin console URL: %q", raw)
+	}
+	return u.String(), nil
+}
+
+// editControlServerAdminURL prompts for a new admin console URL for
+// the saved control server at index i in servers.
+func (a *App) editControlServerAdminURL(servers []controlserver.Server, i int, done func()) {
+	Prompt{
+		Heading:     "Admin Console",
+		Body:        fmt.Sprintf("The URL of the admin console for %v. Leave it empty if the server doesn't have one.", servers[i].Name),
+		Placeholder: "https://headscale-ui.example.com",
+		Purpose:     gtk.InputPurposeURL,
+		Responses: []PromptResponse{
+			{ID: "cancel", Label: "_Cancel"},
+			{ID: "save", Label: "_Save", Appearance: adw.ResponseSuggested, Default: true},
+		},
+	}.Show(a, servers[i].AdminURL, func(response, val string) {
+		if response != "save" {
+			return
+		}
+
+		adminURL, err := validateAdminURL(val)
+		if err != nil {
+			a.reportError("Invalid admin console URL", err)
+			return
+		}
+
+		servers[i].AdminURL = adminURL
+		a.setControlServers(servers)
+		done()
+	})
+}
+
+// switchControlServer checks that server is reachable and then
+// switches the daemon to it.
+func (a *App) switchControlServer(server controlserver.Server) {
+	fail := func(err error) {
+		a.reportError(fmt.Sprintf("Failed to switch to %v", server.Name), err)
+	}
+
+	probeCtx, cancel := context.WithTimeout(context.Background(), controlServerProbeTimeout)
+	defer cancel()
+
+	err := controlserver.Probe(probeCtx, nil, server.URL)
+	if err != nil {
+		fail(fmt.Errorf("probe control server: %w", err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	err = tsutil.SetControlURL(ctx, server.URL)
+	if err != nil {
+		fail(fmt.Errorf("set control URL: %w", err))
+		return
+	}
+	<-a.poller.Poll()
+
+	glib.IdleAdd(func() {
+		a.toast(fmt.Sprintf("Switched to %v", server.Name))
+	})
+}
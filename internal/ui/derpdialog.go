@@ -0,0 +1,184 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"strings"
+	"time"
+
+	"deedles.dev/trayscale/internal/gutil"
+	"deedles.dev/trayscale/internal/listmodels"
+	"deedles.dev/trayscale/internal/netreport"
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/core/gioutil"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+//go:embed derpdialog.ui
+var derpDialogXML string
+
+// DERPDialog shows every DERP region from a netcheck report in a
+// sortable table.
+type DERPDialog struct {
+	app    *App
+	report netreport.Report
+
+	Dialog       *adw.Dialog
+	ToastOverlay *adw.ToastOverlay
+	Title        *adw.WindowTitle
+	RegionsView  *gtk.ColumnView
+}
+
+func NewDERPDialog(a *App, report netreport.Report) *DERPDialog {
+	dialog := DERPDialog{
+		app:    a,
+		report: report,
+	}
+	gutil.FillFromUI(&dialog, derpDialogXML)
+	dialog.init()
+	return &dialog
+}
+
+func (dialog *DERPDialog) init() {
+	dialog.Title.SetSubtitle(fmt.Sprintf("Checked %v", formatTime(dialog.report.Time)))
+
+	model := gioutil.NewListModel[netreport.Region]()
+	for _, region := range dialog.report.Regions {
+		model.Append(region)
+	}
+
+	latency := derpColumn("Latency", netreport.SortLatency, func(r netreport.Region) string { return formatLatency(r.Latency) })
+	columns := []*gtk.ColumnViewColumn{
+		derpColumn("Region", netreport.SortName, func(r netreport.Region) string { return r.Name }),
+		derpColumn("Code", netreport.SortCode, func(r netreport.Region) string { return r.Code }),
+		latency,
+		derpColumn("IPv4", netreport.SortLatencyV4, func(r netreport.Region) string { return formatLatency(r.LatencyV4) }),
+		derpColumn("IPv6", netreport.SortLatencyV6, func(r netreport.Region) string { return formatLatency(r.LatencyV6) }),
+		derpColumn("Home", netreport.SortHome, func(r netreport.Region) string {
+			if r.Home {
+				return "Yes"
+			}
+			return ""
+		}),
+		derpColumn("Nodes", -1, func(r netreport.Region) string { return strings.Join(r.Nodes, ", ") }),
+	}
+	columns[0].SetExpand(true)
+	columns[len(columns)-1].SetExpand(true)
+	for _, column := range columns {
+		column.SetResizable(true)
+		dialog.RegionsView.AppendColumn(column)
+	}
+
+	sorted := gtk.NewSortListModel(model, dialog.RegionsView.Sorter())
+	dialog.RegionsView.SetModel(gtk.NewNoSelection(sorted))
+	dialog.RegionsView.SortByColumn(latency, gtk.SortAscending)
+
+	actions := gio.NewSimpleActionGroup()
+
+	copyJSONAction := gio.NewSimpleAction("copy_json", nil)
+	copyJSONAction.ConnectActivate(func(p *glib.Variant) { dialog.copyJSON() })
+	actions.AddAction(copyJSONAction)
+
+	saveReportAction := gio.NewSimpleAction("save_report", nil)
+	saveReportAction.ConnectActivate(func(p *glib.Variant) { dialog.saveReport() })
+	actions.AddAction(saveReportAction)
+
+	dialog.Dialog.InsertActionGroup("derp", actions)
+}
+
+func (dialog *DERPDialog) Present() {
+	dialog.Dialog.Present(gutil.PointerToWidgetter(dialog.app.window()))
+}
+
+func (dialog *DERPDialog) toast(msg string) {
+	toast := adw.NewToast(msg)
+	toast.SetTimeout(3)
+	dialog.ToastOverlay.AddToast(toast)
+}
+
+func (dialog *DERPDialog) copyJSON() {
+	data, err := dialog.report.JSON()
+	if err != nil {
+		dialog.app.reportError("Failed to encode report", err)
+		return
+	}
+
+	dialog.app.clip(glib.NewValue(string(data)))
+	dialog.toast("Copied report to clipboard")
+}
+
+func (dialog *DERPDialog) saveReport() {
+	data, err := dialog.report.JSON()
+	if err != nil {
+		dialog.app.reportError("Failed to encode report", err)
+		return
+	}
+
+	fd := gtk.NewFileDialog()
+	fd.SetModal(true)
+	fd.SetInitialName(dialog.report.FileName())
+	fd.Save(context.TODO(), dialog.app.window(), func(res gio.AsyncResulter) {
+		f, err := fd.SaveFinish(res)
+		if err != nil {
+			if !gutil.ErrHasCode(err, int(gtk.DialogErrorDismissed)) {
+				dialog.app.reportError("Failed to save report", err)
+			}
+			return
+		}
+
+		err = replaceFile(context.TODO(), f, bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			dialog.app.reportError("Failed to save report", err)
+			return
+		}
+		dialog.toast("Saved report")
+	})
+}
+
+// derpColumn creates a column that shows the text returned by text
+// for each region. If by is negative, the column can't be sorted.
+func derpColumn(title string, by netreport.SortBy, text func(netreport.Region) string) *gtk.ColumnViewColumn {
+	factory := gtk.NewSignalListItemFactory()
+	factory.ConnectSetup(func(obj *glib.Object) {
+		label := gtk.NewLabel("")
+		label.SetXAlign(0)
+		obj.Cast().(*gtk.ListItem).SetChild(label)
+	})
+	factory.ConnectBind(func(obj *glib.Object) {
+		item := obj.Cast().(*gtk.ListItem)
+		region := listmodels.Convert[netreport.Region](item.Item())
+
+		label := item.Child().(*gtk.Label)
+		label.SetText(text(region))
+		if region.Home {
+			label.AddCSSClass("heading")
+		} else {
+			label.RemoveCSSClass("heading")
+		}
+		if region.Reachable() {
+			label.RemoveCSSClass("dim-label")
+		} else {
+			label.AddCSSClass("dim-label")
+		}
+	})
+
+	column := gtk.NewColumnViewColumn(title, &factory.ListItemFactory)
+	if by >= 0 {
+		sorter := gtk.NewCustomSorter(NewObjectComparer(func(r1, r2 netreport.Region) int {
+			return netreport.CompareRegions(r1, r2, by)
+		}))
+		column.SetSorter(&sorter.Sorter)
+	}
+	return column
+}
+
+func formatLatency(d time.Duration) string {
+	if d <= 0 {
+		return "—"
+	}
+	return d.Round(100 * time.Microsecond).String()
+}
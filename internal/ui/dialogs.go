@@ -22,6 +22,10 @@ type Confirmation struct {
 	Body    string
 	Accept  string
 	Reject  string
+
+	// Extra, if not nil, is called to create a widget that is shown
+	// below the body.
+	Extra func() gtk.Widgetter
 }
 
 func (d Confirmation) Show(a *App, res func(bool)) {
@@ -31,6 +35,9 @@ func (d Confirmation) Show(a *App, res func(bool)) {
 	dialog.AddResponse("accept", d.Accept)
 	dialog.SetResponseAppearance("accept", adw.ResponseSuggested)
 	dialog.SetDefaultResponse("accept")
+	if d.Extra != nil {
+		dialog.SetExtraChild(d.Extra())
+	}
 
 	dialog.ConnectResponse(func(response string) {
 		res(response == "accept")
@@ -45,6 +52,10 @@ type Prompt struct {
 	Placeholder string
 	Purpose     gtk.InputPurpose
 	Responses   []PromptResponse
+
+	// Extra, if not nil, is called to create a widget that is shown
+	// below the input.
+	Extra func() gtk.Widgetter
 }
 
 type PromptResponse struct {
@@ -59,9 +70,18 @@ func (d Prompt) Show(a *App, initialValue string, res func(response, val string)
 	input.SetText(initialValue)
 	input.SetInputPurpose(d.Purpose)
 	input.SetPlaceholderText(d.Placeholder)
+	if d.Purpose == gtk.InputPurposePassword || d.Purpose == gtk.InputPurposePIN {
+		input.SetVisibility(false)
+	}
 
 	dialog := adw.NewAlertDialog(d.Heading, d.Body)
 	dialog.SetExtraChild(input)
+	if d.Extra != nil {
+		box := gtk.NewBox(gtk.OrientationVertical, 12)
+		box.Append(input)
+		box.Append(d.Extra())
+		dialog.SetExtraChild(box)
+	}
 
 	def := "activate"
 	for _, r := range d.Responses {
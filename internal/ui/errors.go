@@ -0,0 +1,154 @@
+package ui
+
+import (
+	_ "embed"
+	"log/slog"
+	"strings"
+	"time"
+
+	"deedles.dev/trayscale/internal/errreport"
+	"deedles.dev/trayscale/internal/gutil"
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotk4/pkg/pango"
+)
+
+//go:embed recenterrors.ui
+var recentErrorsXML string
+
+// reportError logs err and tells the user about it with msg, which
+// should briefly describe what failed. The user can then look at the
+// details of err. If the main window isn't open, a notification is
+// sent instead of showing a toast.
+//
+// Unlike most methods, it is safe to call from any goroutine.
+func (a *App) reportError(msg string, err error) {
+	slog.Error(msg, "err", err)
+
+	report := errreport.Report{
+		Time:    time.Now(),
+		Message: msg,
+		Err:     err,
+	}
+	a.errors.Add(report)
+
+	glib.IdleAdd(func() {
+		if a.win == nil {
+			n := newNotification(report.Message, report.Err.Error())
+			n.SetDefaultAction("app.recent_errors")
+			a.app.SendNotification("error", n)
+			return
+		}
+
+		toast := a.win.Toast(report.Message)
+		toast.SetTimeout(5)
+		toast.SetButtonLabel("_Details")
+		toast.ConnectButtonClicked(func() { a.showErrorDetails(report) })
+	})
+}
+
+// showErrorDetails shows the full chain of errors that caused a
+// reported error.
+func (a *App) showErrorDetails(report errreport.Report) {
+	Info{
+		Heading: report.Message,
+		Body:    formatTime(report.Time),
+		Extra: func() gtk.Widgetter {
+			w := gtk.NewLabel(strings.Join(errreport.Chain(report.Err), "\n"))
+			w.AddCSSClass("code")
+			w.AddCSSClass("monospace")
+			w.AddCSSClass("frame")
+			w.SetSelectable(true)
+			w.SetWrap(true)
+			w.SetWrapMode(pango.WrapWordChar)
+			w.SetXAlign(0)
+			return w
+		},
+	}.Show(a, nil)
+}
+
+// showRecentErrors shows the errors that have been reported since the
+// app was started, opening the main window first if it isn't already.
+func (a *App) showRecentErrors() {
+	if a.win == nil {
+		a.app.Activate()
+	}
+	NewRecentErrorsDialog(a).Present()
+}
+
+// RecentErrorsDialog lists recently reported errors.
+type RecentErrorsDialog struct {
+	app  *App
+	rows []*adw.ActionRow
+
+	Dialog       *adw.Dialog
+	ToastOverlay *adw.ToastOverlay
+	Stack        *gtk.Stack
+	ErrorsList   *gtk.ListBox
+	CopyButton   *gtk.Button
+	ClearButton  *gtk.Button
+}
+
+func NewRecentErrorsDialog(a *App) *RecentErrorsDialog {
+	dialog := RecentErrorsDialog{app: a}
+	gutil.FillFromUI(&dialog, recentErrorsXML)
+	dialog.init()
+	return &dialog
+}
+
+func (dialog *RecentErrorsDialog) init() {
+	dialog.refresh()
+
+	dialog.CopyButton.ConnectClicked(func() {
+		var sb strings.Builder
+		for _, report := range dialog.app.errors.All() {
+			sb.WriteString(report.Details())
+		}
+		dialog.app.clip(glib.NewValue(sb.String()))
+
+		toast := adw.NewToast("Copied errors to clipboard")
+		toast.SetTimeout(3)
+		dialog.ToastOverlay.AddToast(toast)
+	})
+
+	dialog.ClearButton.ConnectClicked(func() {
+		dialog.app.errors.Clear()
+		dialog.refresh()
+	})
+}
+
+func (dialog *RecentErrorsDialog) refresh() {
+	for _, row := range dialog.rows {
+		dialog.ErrorsList.Remove(row)
+	}
+	dialog.rows = dialog.rows[:0]
+
+	reports := dialog.app.errors.All()
+	for _, report := range reports {
+		row := adw.NewActionRow()
+		row.SetUseMarkup(false)
+		row.SetTitle(report.Message)
+		row.SetSubtitle(report.Err.Error())
+		row.SetSubtitleLines(2)
+		row.AddPrefix(gtk.NewLabel(formatTime(report.Time)))
+		row.AddSuffix(gtk.NewImageFromIconName("go-next-symbolic"))
+		row.SetActivatable(true)
+		row.ConnectActivated(func() { dialog.app.showErrorDetails(report) })
+
+		dialog.ErrorsList.Append(row)
+		dialog.rows = append(dialog.rows, row)
+	}
+
+	dialog.CopyButton.SetSensitive(len(reports) > 0)
+	dialog.ClearButton.SetSensitive(len(reports) > 0)
+	if len(reports) == 0 {
+		dialog.Stack.SetVisibleChildName("empty")
+		return
+	}
+	dialog.Stack.SetVisibleChildName("errors")
+}
+
+func (dialog *RecentErrorsDialog) Present() {
+	dialog.Dialog.Present(gutil.PointerToWidgetter(dialog.app.window()))
+}
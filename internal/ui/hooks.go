@@ -0,0 +1,105 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"deedles.dev/trayscale/internal/hooks"
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// postSaveHooks returns the configured post-save hook commands and
+// the timeout for each of them.
+func (a *App) postSaveHooks() ([]string, time.Duration) {
+	if a.settings == nil {
+		return nil, 0
+	}
+	return a.settings.Strv("taildrop-post-save-hooks"), time.Duration(a.settings.Uint("taildrop-hook-timeout")) * time.Second
+}
+
+// runPostSaveHooks runs every configured post-save hook for f, one at
+// a time in the order that they're listed. A failing hook doesn't
+// prevent the ones after it from running.
+func (a *App) runPostSaveHooks(f hooks.File) {
+	commands, timeout := a.postSaveHooks()
+	for _, command := range commands {
+		slog := slog.With("command", command, "path", f.Path)
+
+		r := hooks.Run(context.Background(), command, f, timeout)
+		if len(r.Output) > 0 {
+			slog.Info("post-save hook output", "output", string(r.Output), "truncated", r.Truncated)
+		}
+		if r.Err != nil {
+			slog.Error("post-save hook failed", "duration", r.Duration, "err", r.Err)
+			glib.IdleAdd(func() {
+				a.notify("Post-save Hook Failed", fmt.Sprintf("%v: %v", f.Name, r.Err))
+			})
+			continue
+		}
+		slog.Info("post-save hook finished", "duration", r.Duration)
+	}
+}
+
+// initPostSaveHooks populates the post-save hooks group of the
+// preferences dialog and keeps it in sync with settings.
+func (a *App) initPostSaveHooks(dialog *PreferencesDialog) {
+	a.settings.Bind("taildrop-hook-timeout", dialog.TaildropHookTimeoutAdjustment.Object, "value", gio.SettingsBindDefault)
+
+	var rows []*adw.ActionRow
+	var refresh func()
+	save := func(commands []string) {
+		a.settings.SetStrv("taildrop-post-save-hooks", commands)
+		refresh()
+	}
+
+	refresh = func() {
+		for _, row := range rows {
+			dialog.TaildropHooksGroup.Remove(row)
+		}
+		rows = rows[:0]
+
+		commands := a.settings.Strv("taildrop-post-save-hooks")
+		for i, command := range commands {
+			row := adw.NewActionRow()
+			row.SetTitle(command)
+			row.SetTitleSelectable(true)
+			row.AddCSSClass("monospace")
+
+			remove := gtk.NewButtonFromIconName("user-trash-symbolic")
+			remove.SetTooltipText("Remove hook")
+			remove.SetVAlign(gtk.AlignCenter)
+			remove.AddCSSClass("flat")
+			remove.ConnectClicked(func() {
+				save(slices.Delete(commands, i, i+1))
+			})
+			row.AddSuffix(remove)
+
+			dialog.TaildropHooksGroup.Add(row)
+			rows = append(rows, row)
+		}
+	}
+	refresh()
+
+	dialog.TaildropAddHookButton.ConnectClicked(func() {
+		Prompt{
+			Heading:     "Add Post-save Hook",
+			Body:        "The command is run with sh after each file is saved. The file is described by the TRAYSCALE_FILE_PATH, TRAYSCALE_FILE_NAME, TRAYSCALE_FILE_SENDER, TRAYSCALE_FILE_SIZE, and TRAYSCALE_FILE_AUTO environment variables. TRAYSCALE_FILE_AUTO is 1 if the file was saved automatically and 0 otherwise. TRAYSCALE_FILE_SENDER is always empty for now because Tailscale doesn't report who sent a file.",
+			Placeholder: `notify-send "Saved $TRAYSCALE_FILE_NAME"`,
+			Responses: []PromptResponse{
+				{ID: "cancel", Label: "_Cancel"},
+				{ID: "add", Label: "_Add", Appearance: adw.ResponseSuggested, Default: true},
+			},
+		}.Show(a, "", func(response, val string) {
+			if (response != "add") || (val == "") {
+				return
+			}
+			save(append(a.settings.Strv("taildrop-post-save-hooks"), val))
+		})
+	})
+}
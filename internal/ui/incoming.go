@@ -0,0 +1,150 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"deedles.dev/trayscale/internal/autosave"
+	"deedles.dev/trayscale/internal/gutil"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/inhies/go-bytesize"
+	"tailscale.com/client/tailscale/apitype"
+)
+
+// incomingFileNotificationID returns the ID of the notification for
+// the waiting file called name so that it can be withdrawn once the
+// file has been dealt with.
+func incomingFileNotificationID(name string) string {
+	return "incoming-file:" + name
+}
+
+// notifyIncomingFile sends a notification about a new waiting file
+// with buttons for dealing with it directly.
+func (a *App) notifyIncomingFile(file apitype.WaitingFile) {
+	a.notifyWaitingFile(file.Name, "New Incoming File", fmt.Sprintf("%v (%v)", file.Name, bytesize.ByteSize(file.Size)))
+}
+
+// notifyWaitingFile sends a notification with the given title and body
+// about the waiting file called name with buttons for dealing with it
+// directly.
+func (a *App) notifyWaitingFile(name, title, body string) {
+	target := glib.NewVariantString(name)
+
+	n := newNotification(title, body)
+	n.SetDefaultActionAndTarget("app.show_waiting_file", target)
+	n.AddButtonWithTarget("Save to Downloads", "app.save_waiting_file", target)
+	n.AddButtonWithTarget("Save as…", "app.save_waiting_file_as", target)
+	n.AddButtonWithTarget("Delete", "app.delete_waiting_file", target)
+	n.AddButtonWithTarget("Show", "app.show_waiting_file", target)
+	a.app.SendNotification(incomingFileNotificationID(name), n)
+}
+
+// notifySaved sends a notification about a file that has been saved
+// to path with buttons for opening it.
+func (a *App) notifySaved(name, path string) {
+	target := glib.NewVariantString(path)
+
+	n := newNotification("File Saved", fmt.Sprintf("%v was saved to %v", name, filepath.Dir(path)))
+	n.SetDefaultActionAndTarget("app.open_saved_file", target)
+	n.AddButtonWithTarget("Open", "app.open_saved_file", target)
+	n.AddButtonWithTarget("Show in Folder", "app.show_saved_file", target)
+	a.app.SendNotification(incomingFileNotificationID(name), n)
+}
+
+// downloadsDir returns the user's downloads directory.
+func downloadsDir() string {
+	if dir := glib.GetUserSpecialDir(glib.UserDirectoryDownload); dir != "" {
+		return dir
+	}
+	return filepath.Join(glib.GetHomeDir(), "Downloads")
+}
+
+// saveToDownloads saves the waiting file called name to the user's
+// downloads directory using the configured conflict policy.
+func (a *App) saveToDownloads(ctx context.Context, name string) {
+	a.app.WithdrawNotification(incomingFileNotificationID(name))
+
+	dir := downloadsDir()
+	policy := a.conflictPolicy()
+	received := a.received(name)
+	go func() {
+		err := os.MkdirAll(dir, 0o755)
+		if err != nil {
+			a.reportError(fmt.Sprintf("Failed to save %v", name), err)
+			return
+		}
+
+		path, action, err := a.saveFileWithPolicy(ctx, policy, name, autosave.Path(dir, name), received)
+		if err != nil {
+			a.reportError(fmt.Sprintf("Failed to save %v", name), err)
+			return
+		}
+
+		glib.IdleAdd(func() {
+			switch {
+			case action == autosave.ActionSkip:
+				a.notify("Taildrop", fmt.Sprintf("%v already exists in %v and was left waiting", name, dir))
+			case action == autosave.ActionDiscard:
+				a.notify("Taildrop", fmt.Sprintf("%v was already saved in %v", name, dir))
+			default:
+				a.notifySaved(name, path)
+			}
+		})
+	}()
+}
+
+// promptSaveFile asks the user where to save the waiting file called
+// name and then saves it there.
+func (a *App) promptSaveFile(name string) {
+	a.app.WithdrawNotification(incomingFileNotificationID(name))
+
+	dialog := gtk.NewFileDialog()
+	dialog.SetModal(true)
+	dialog.SetInitialName(name)
+	dialog.Save(context.TODO(), a.window(), func(res gio.AsyncResulter) {
+		f, err := dialog.SaveFinish(res)
+		if err != nil {
+			if !gutil.ErrHasCode(err, int(gtk.DialogErrorDismissed)) {
+				slog.Error("save file", "err", err)
+			}
+			return
+		}
+
+		a.saveFileAs(name, f, func(path string) {
+			a.notifySaved(name, path)
+		})
+	})
+}
+
+// deleteWaitingFileFromNotification deletes the waiting file called
+// name.
+func (a *App) deleteWaitingFileFromNotification(ctx context.Context, name string) {
+	a.app.WithdrawNotification(incomingFileNotificationID(name))
+	go a.deleteWaitingFile(ctx, name)
+}
+
+// showWaitingFile opens the main window to the list of waiting files.
+func (a *App) showWaitingFile(name string) {
+	a.app.WithdrawNotification(incomingFileNotificationID(name))
+	a.app.Activate()
+	if a.win != nil {
+		a.win.ShowSelf()
+	}
+}
+
+// openSavedFile opens the saved file at path with its default
+// application.
+func (a *App) openSavedFile(ctx context.Context, path string) {
+	gtk.NewFileLauncher(gio.NewFileForPath(path)).Launch(ctx, a.window(), nil)
+}
+
+// showSavedFile opens the folder containing the saved file at path in
+// the file manager.
+func (a *App) showSavedFile(ctx context.Context, path string) {
+	gtk.NewFileLauncher(gio.NewFileForPath(path)).OpenContainingFolder(ctx, a.window(), nil)
+}
@@ -3,14 +3,23 @@ package ui
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
 
+	"deedles.dev/trayscale/internal/archive"
 	"deedles.dev/trayscale/internal/autosave"
+	"deedles.dev/trayscale/internal/clipfile"
 	"deedles.dev/trayscale/internal/giofs"
+	"deedles.dev/trayscale/internal/hooks"
 	"deedles.dev/trayscale/internal/tsutil"
 	"github.com/diamondburned/gotk4/pkg/core/gioutil"
 	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"tailscale.com/tailcfg"
 )
 
@@ -21,23 +30,27 @@ func (a *App) pushFile(ctx context.Context, peerID tailcfg.StableNodeID, file gi
 	slog := slog.With("peer", peerID, "path", file.Path())
 	slog.Info("starting file push")
 
-	r, size, name, err := giofs.Reader(ctx, file)
+	r, size, name, err := giofs.Reader(ctx, file, a.archiveOptions())
 	if err != nil {
-		slog.Error("open file", "err", err)
+		a.reportError(fmt.Sprintf("Failed to open %v", file.Basename()), err)
 		return
 	}
 	defer r.Close()
 
 	err = tsutil.PushFile(ctx, peerID, size, name, r)
 	if err != nil {
-		slog.Error("push file", "err", err)
+		a.reportError(fmt.Sprintf("Failed to send %v", name), err)
 		return
 	}
 
 	slog.Info("done pushing file")
 }
 
-func (a *App) saveFile(ctx context.Context, name string, file gio.Filer) error {
+// saveFile saves the waiting file called name to file and returns the
+// path that it was saved to, which is the path of the extracted
+// directory for extracted archives and may be empty for non-local
+// files.
+func (a *App) saveFile(ctx context.Context, name string, file gio.Filer) (string, error) {
 	a.spin()
 	defer a.stopSpin()
 
@@ -47,36 +60,326 @@ func (a *App) saveFile(ctx context.Context, name string, file gio.Filer) error {
 	r, size, err := tsutil.GetWaitingFile(ctx, name)
 	if err != nil {
 		slog.Error("get file", "err", err)
-		return err
+		return "", err
 	}
 	defer r.Close()
 
+	saved := file.Path()
+	format, isArchive := archive.FormatOf(name)
+	if a.extractArchives() && isArchive && file.Path() != "" {
+		dir, err := extractArchive(r, format, size, file.Path())
+		if err != nil {
+			slog.Error("extract archive", "err", err)
+			return "", err
+		}
+		slog = slog.With("dir", dir)
+		saved = dir
+	} else {
+		err := replaceFile(ctx, file, r, size)
+		if err != nil {
+			slog.Error("write file", "err", err)
+			return "", err
+		}
+	}
+
+	err = tsutil.DeleteWaitingFile(ctx, name)
+	if err != nil {
+		slog.Error("delete file", "err", err)
+		return "", err
+	}
+
+	<-a.poller.Poll()
+	slog.Info("done saving file")
+
+	if saved != "" {
+		_, auto := a.autoSaving.Load(name)
+		go a.runPostSaveHooks(hooks.File{
+			Path: saved,
+			Name: name,
+			Size: size,
+			Auto: auto,
+		})
+	}
+
+	return saved, nil
+}
+
+func replaceFile(ctx context.Context, file gio.Filer, r io.Reader, size int64) error {
 	// Replace writes to a temporary name and only renames over the
 	// destination when the stream is closed. Leaving it open leaves
 	// hidden .goutputstream-* files and never updates an existing target.
 	s, err := file.Replace(ctx, "", false, gio.FileCreateNone)
 	if err != nil {
-		slog.Error("create file", "err", err)
-		return err
+		return fmt.Errorf("create file: %w", err)
 	}
 
 	w := gioutil.Writer(ctx, s)
 	_, copyErr := io.CopyN(w, r, size)
 	closeErr := w.Close()
-	if err := errors.Join(copyErr, closeErr); err != nil {
-		slog.Error("write file", "err", err)
-		return err
+	return errors.Join(copyErr, closeErr)
+}
+
+const (
+	// archiveExpansion is the largest ratio between the size of an
+	// archive and the total size of its extracted contents that will be
+	// allowed. minArchiveLimit is used instead for small archives.
+	archiveExpansion = 100
+	minArchiveLimit  = 1 << 30
+
+	maxArchiveFiles = 100000
+)
+
+// extractArchive unpacks the archive in the given format read from r
+// into a new directory next to dest named after the archive, adding a
+// numeric suffix if something already exists there. The archive is
+// extracted into a temporary directory first so that a failed
+// extraction doesn't leave a partial directory behind under the final
+// name.
+func extractArchive(r io.Reader, format archive.Format, size int64, dest string) (string, error) {
+	parent := filepath.Dir(dest)
+	tmp, err := os.MkdirTemp(parent, ".trayscale-extract-*")
+	if err != nil {
+		return "", err
 	}
+	defer os.RemoveAll(tmp)
 
-	err = tsutil.DeleteWaitingFile(ctx, name)
+	limits := archive.Limits{
+		MaxBytes: max(size*archiveExpansion, minArchiveLimit),
+		MaxFiles: maxArchiveFiles,
+	}
+	stats, err := archive.Extract(r, format, tmp, limits)
 	if err != nil {
-		slog.Error("delete file", "err", err)
-		return err
+		return "", err
+	}
+	if stats.Skipped > 0 {
+		slog.Warn("skipped unsupported archive entries", "archive", dest, "skipped", stats.Skipped)
+	}
+
+	err = os.Chmod(tmp, 0o755)
+	if err != nil {
+		return "", err
+	}
+
+	dir := autosave.UniquePath(parent, archive.DirName(dest))
+	err = os.Rename(tmp, dir)
+	if err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// autoSaveLimits returns the configured safeguards for auto-saving.
+func (a *App) autoSaveLimits() autosave.Limits {
+	if a.settings == nil {
+		return autosave.Limits{}
+	}
+
+	return autosave.Limits{
+		MaxSize: int64(a.settings.Uint("taildrop-auto-save-max-size")) << 20,
+		MinFree: int64(a.settings.Uint("taildrop-auto-save-min-free")) << 20,
+		Confirm: a.settings.Boolean("taildrop-auto-save-confirm"),
+	}
+}
+
+// holdAutoSave leaves a file that is not allowed to be auto-saved
+// waiting and notifies the user so that they can decide what to do
+// with it. The file is remembered so that it isn't checked again until
+// the settings change. It must be called from the GTK thread.
+func (a *App) holdAutoSave(limits autosave.Limits, hold autosave.Hold, file autosave.File) {
+	slog.Info("holding taildrop file for manual save", "file", file.Name, "reason", hold)
+	a.autoSaveFailed.Store(file.Name, struct{}{})
+	a.notifyWaitingFile(file.Name, "Incoming File Needs Confirmation", limits.Describe(hold, file))
+}
+
+// updateFilesSeen records when each currently waiting file was first
+// seen and forgets files that are no longer waiting.
+func (a *App) updateFilesSeen() {
+	if a.filesSeen == nil {
+		a.filesSeen = make(map[string]time.Time)
 	}
 
+	waiting := make(map[string]bool, len(*a.files))
+	now := time.Now()
+	for _, f := range *a.files {
+		waiting[f.Name] = true
+		if _, ok := a.filesSeen[f.Name]; !ok {
+			a.filesSeen[f.Name] = now
+		}
+	}
+	maps.DeleteFunc(a.filesSeen, func(name string, _ time.Time) bool {
+		return !waiting[name]
+	})
+}
+
+// received returns the time that the waiting file called name was
+// first seen waiting. Taildrop doesn't report when a file arrived, so
+// this is the closest approximation that is available, and it is the
+// time that the app was started for files that were already waiting
+// then.
+func (a *App) received(name string) time.Time {
+	if t, ok := a.filesSeen[name]; ok {
+		return t
+	}
+	return time.Now()
+}
+
+// conflictPolicy returns the configured policy for saving files over
+// existing ones.
+func (a *App) conflictPolicy() autosave.Policy {
+	if a.settings == nil {
+		return autosave.PolicyRename
+	}
+	policy, _ := autosave.ParsePolicy(a.settings.String("taildrop-conflict-policy"))
+	return policy
+}
+
+// saveFileWithPolicy saves the waiting file called name to dest after
+// resolving any conflict with an existing file there according to
+// policy. It returns the action that was taken and, if the file was
+// saved, where it was saved to.
+func (a *App) saveFileWithPolicy(ctx context.Context, policy autosave.Policy, name, dest string, received time.Time) (string, autosave.Action, error) {
+	identical := func(path string) (bool, error) {
+		r, size, err := tsutil.GetWaitingFile(ctx, name)
+		if err != nil {
+			return false, err
+		}
+		defer r.Close()
+		return autosave.Identical(path, size, r)
+	}
+
+	path, action, err := autosave.Resolve(policy, dest, received, identical)
+	if err != nil {
+		slog.Error("resolve save conflict", "filename", name, "path", dest, "policy", policy, "err", err)
+		return "", action, err
+	}
+
+	switch action {
+	case autosave.ActionSave:
+		saved, err := a.saveFile(ctx, name, gio.NewFileForPath(path))
+		return saved, action, err
+
+	case autosave.ActionDiscard:
+		slog.Info("identical file already exists", "filename", name, "path", dest)
+		return "", action, a.deleteWaitingFile(ctx, name)
+
+	default:
+		slog.Info("leaving conflicting file waiting", "filename", name, "path", dest, "policy", policy)
+		return "", action, nil
+	}
+}
+
+// saveFileAs saves the waiting file called name to a destination
+// chosen by the user in a file dialog. If saved is not nil, it is
+// called on the GTK thread with the path that the file was saved to
+// once it has been saved successfully. It must be called from the GTK
+// thread.
+func (a *App) saveFileAs(name string, file gio.Filer, saved func(path string)) {
+	path := file.Path()
+	direct := (path == "") || (a.extractArchives() && archive.IsArchive(name))
+	go func() {
+		var err error
+		if direct {
+			path, err = a.saveFile(context.TODO(), name, file)
+		} else {
+			// The file dialog has already asked the user whether or not
+			// to replace an existing file, so the configured conflict
+			// policy doesn't apply.
+			path, _, err = a.saveFileWithPolicy(context.TODO(), autosave.PolicyOverwrite, name, path, time.Now())
+		}
+		if err != nil {
+			a.reportError(fmt.Sprintf("Failed to save %v", name), err)
+			return
+		}
+		if (saved != nil) && (path != "") {
+			glib.IdleAdd(func() { saved(path) })
+		}
+	}()
+}
+
+// saveFilesTo saves each of the named waiting files into dir using the
+// configured conflict policy, and then shows a single toast
+// summarizing the result. It must be called from the GTK thread.
+func (a *App) saveFilesTo(ctx context.Context, names []string, dir string) {
+	policy := a.conflictPolicy()
+	extract := a.extractArchives()
+	received := make(map[string]time.Time, len(names))
+	for _, name := range names {
+		received[name] = a.received(name)
+	}
+
+	go func() {
+		var saved, skipped int
+		var errs []error
+		for _, name := range names {
+			dest := autosave.Path(dir, name)
+
+			// Extracted archives always pick their own unique directory
+			// name.
+			action := autosave.ActionSave
+			var err error
+			if extract && archive.IsArchive(name) {
+				_, err = a.saveFile(ctx, name, gio.NewFileForPath(dest))
+			} else {
+				_, action, err = a.saveFileWithPolicy(ctx, policy, name, dest, received[name])
+			}
+
+			switch {
+			case err != nil:
+				errs = append(errs, fmt.Errorf("%v: %w", name, err))
+			case action == autosave.ActionSave:
+				saved++
+			default:
+				skipped++
+			}
+		}
+
+		msg := fmt.Sprintf("Saved %v of %v file(s) to %v", saved, len(names), filepath.Base(dir))
+		if skipped > 0 {
+			msg += fmt.Sprintf(", %v already existed", skipped)
+		}
+		if len(errs) > 0 {
+			msg += fmt.Sprintf(", %v failed", len(errs))
+			a.reportError(msg, errors.Join(errs...))
+			return
+		}
+		glib.IdleAdd(func() {
+			if a.win != nil {
+				a.win.Toast(msg)
+			}
+		})
+	}()
+}
+
+// deleteWaitingFiles deletes each of the named waiting files and then
+// shows a single toast summarizing the result.
+func (a *App) deleteWaitingFiles(ctx context.Context, names []string) {
+	var errs []error
+	for _, name := range names {
+		err := tsutil.DeleteWaitingFile(ctx, name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", name, err))
+		}
+	}
 	<-a.poller.Poll()
-	slog.Info("done saving file")
-	return nil
+
+	if len(errs) > 0 {
+		deleted := len(names) - len(errs)
+		a.reportError(fmt.Sprintf("Deleted %v of %v file(s), %v failed", deleted, len(names), len(errs)), errors.Join(errs...))
+		return
+	}
+
+	msg := fmt.Sprintf("Deleted %v file(s)", len(names))
+	glib.IdleAdd(func() {
+		if a.win != nil {
+			a.win.Toast(msg)
+		}
+	})
+}
+
+// extractArchives reports whether received directory archives should
+// be unpacked when saved.
+func (a *App) extractArchives() bool {
+	return (a.settings != nil) && a.settings.Boolean("taildrop-extract-archives")
 }
 
 func (a *App) autoSaveSettings() (enabled bool, dir string) {
@@ -139,6 +442,13 @@ func (a *App) maybeAutoSaveFiles() {
 	})
 
 	skip := make(map[string]bool)
+	for _, f := range *a.files {
+		// Clipboard transfers are left in the inbox for the actions in
+		// their notifications.
+		if _, ok := clipfile.Parse(f.Name); ok {
+			skip[f.Name] = true
+		}
+	}
 	a.autoSaving.Range(func(key, _ any) bool {
 		if name, ok := key.(string); ok {
 			skip[name] = true
@@ -152,19 +462,90 @@ func (a *App) maybeAutoSaveFiles() {
 		return true
 	})
 
+	sizes := make(map[string]int64, len(*a.files))
+	for _, f := range *a.files {
+		sizes[f.Name] = f.Size
+	}
+
+	rules := a.autoSaveRules()
+	extract := a.extractArchives()
+	policy := a.conflictPolicy()
+	limits := a.autoSaveLimits()
+	scan := a.scanSettings()
 	for _, name := range autosave.Files(enabled, dir, *a.files, skip) {
+		// Tailscale doesn't report who sent a waiting file, so rules
+		// and limits can only check the file itself.
+		file := autosave.File{Name: name, Size: sizes[name]}
+
+		// The free space check has to wait until the destination is
+		// known to exist.
+		if hold := limits.Check(file, -1); hold != autosave.HoldNone {
+			a.holdAutoSave(limits, hold, file)
+			continue
+		}
+
 		if _, loaded := a.autoSaving.LoadOrStore(name, struct{}{}); loaded {
 			continue
 		}
 
-		// Avoid overwriting an existing file; pick "name (1).ext", etc.
-		dest := autosave.UniquePath(dir, name)
-		go func(name, dest string) {
+		destDir, err := a.autoSaveDest(rules, dir, file)
+		if err != nil {
+			slog.Error("taildrop auto-save rule", "file", name, "err", err)
+			a.autoSaving.Delete(name)
+			a.autoSaveFailed.Store(name, struct{}{})
+			continue
+		}
+
+		go func(name, destDir string, received time.Time) {
 			defer a.autoSaving.Delete(name)
-			err := a.saveFile(context.Background(), name, gio.NewFileForPath(dest))
+
+			err := os.MkdirAll(destDir, 0o755)
 			if err != nil {
+				slog.Error("create taildrop auto-save directory", "dir", destDir, "err", err)
 				a.autoSaveFailed.Store(name, struct{}{})
+				return
+			}
+
+			if limits.MinFree > 0 {
+				free, err := autosave.FreeSpace(destDir)
+				if err != nil {
+					slog.Warn("check free space", "dir", destDir, "err", err)
+					free = -1
+				}
+				if hold := limits.Check(file, free); hold != autosave.HoldNone {
+					glib.IdleAdd(func() { a.holdAutoSave(limits, hold, file) })
+					return
+				}
+			}
+
+			if scan.Enabled && !a.scanWaitingFile(context.Background(), scan, name) {
+				a.autoSaveFailed.Store(name, struct{}{})
+				return
+			}
+
+			// Extracted archives always pick their own unique directory
+			// name.
+			dest := autosave.Path(destDir, name)
+			if extract && archive.IsArchive(name) {
+				_, err := a.saveFile(context.Background(), name, gio.NewFileForPath(dest))
+				if err != nil {
+					a.autoSaveFailed.Store(name, struct{}{})
+				}
+				return
+			}
+
+			_, action, err := a.saveFileWithPolicy(context.Background(), policy, name, dest, received)
+			if (err != nil) || (action == autosave.ActionSkip) {
+				// Skipped files are remembered the same way as failures so
+				// that they stay waiting instead of being retried on every
+				// poll.
+				a.autoSaveFailed.Store(name, struct{}{})
+			}
+			if (err == nil) && (action == autosave.ActionSkip) {
+				glib.IdleAdd(func() {
+					a.notify("Incoming File Needs Confirmation", fmt.Sprintf("%v already exists in %v and was left waiting", name, destDir))
+				})
 			}
-		}(name, dest)
+		}(name, destDir, a.received(name))
 	}
 }
@@ -0,0 +1,179 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"deedles.dev/trayscale/internal/gutil"
+	"deedles.dev/trayscale/internal/tsutil"
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotk4/pkg/pango"
+	"github.com/skip2/go-qrcode"
+)
+
+// loginQRSize is the size, in pixels, at which the login QR code is
+// rendered.
+const loginQRSize = 256
+
+// showLoginDialog shows a dialog with the authentication URL for an
+// interactive login, both as a link and as a QR code so that it can
+// be opened on another device. The dialog closes itself once the login
+// is no longer needed. See closeLoginDialog.
+func (a *App) showLoginDialog(url string) {
+	a.closeLoginDialog()
+
+	picture := gtk.NewPicture()
+	picture.SetSizeRequest(loginQRSize*3/4, loginQRSize*3/4)
+	picture.SetContentFit(gtk.ContentFitContain)
+	picture.SetHAlign(gtk.AlignCenter)
+	picture.SetAlternativeText("QR code for the login link")
+	qr, err := qrcode.Encode(url, qrcode.Medium, loginQRSize)
+	if err != nil {
+		slog.Error("encode login QR code", "err", err)
+		picture.SetVisible(false)
+	} else if texture := previewTexture(qr); texture != nil {
+		picture.SetPaintable(texture)
+	}
+
+	link := gtk.NewLabel(url)
+	link.SetSelectable(true)
+	link.SetWrap(true)
+	link.SetWrapMode(pango.WrapWordChar)
+	link.SetHExpand(true)
+	link.SetXAlign(0)
+	link.AddCSSClass("monospace")
+	link.AddCSSClass("caption")
+
+	copyButton := gtk.NewButtonFromIconName("edit-copy-symbolic")
+	copyButton.SetTooltipText("Copy link")
+	copyButton.SetVAlign(gtk.AlignCenter)
+	copyButton.AddCSSClass("flat")
+	copyButton.ConnectClicked(func() {
+		a.clip(glib.NewValue(url))
+		copyButton.SetIconName("object-select-symbolic")
+		copyButton.SetTooltipText("Copied")
+	})
+
+	linkBox := gtk.NewBox(gtk.OrientationHorizontal, 6)
+	linkBox.Append(link)
+	linkBox.Append(copyButton)
+
+	box := gtk.NewBox(gtk.OrientationVertical, 12)
+	box.Append(picture)
+	box.Append(linkBox)
+
+	dialog := adw.NewAlertDialog(
+		"Log In to Tailscale",
+		"Scan the code with another device or open the link in a browser on this one.",
+	)
+	dialog.SetExtraChild(box)
+	dialog.AddResponse("cancel", "_Cancel")
+	dialog.SetCloseResponse("cancel")
+	dialog.AddResponse("browser", "_Open Browser")
+	dialog.SetResponseAppearance("browser", adw.ResponseSuggested)
+	dialog.SetDefaultResponse("browser")
+
+	dialog.ConnectResponse(func(response string) {
+		if response == "browser" {
+			gtk.NewURILauncher(url).Launch(context.TODO(), a.window(), nil)
+		}
+	})
+	dialog.ConnectClosed(func() {
+		if a.loginDialog == dialog {
+			a.loginDialog = nil
+		}
+	})
+
+	a.loginDialog = dialog
+	dialog.Present(gutil.PointerToWidgetter(a.window()))
+}
+
+// closeLoginDialog closes the login dialog if it is open.
+func (a *App) closeLoginDialog() {
+	if a.loginDialog == nil {
+		return
+	}
+
+	dialog := a.loginDialog
+	a.loginDialog = nil
+	dialog.ForceClose()
+}
+
+// updateLoginDialog closes the login dialog once the daemon no longer
+// needs to be logged in to.
+func (a *App) updateLoginDialog(status *tsutil.IPNStatus) {
+	if !status.NeedsAuth() {
+		a.closeLoginDialog()
+	}
+}
+
+// authKeyLoginTimeout is how long to wait for a login with an auth key
+// to connect before giving up.
+const authKeyLoginTimeout = 30 * time.Second
+
+// showAuthKeyLogin prompts for a pre-auth key, and optionally tags and
+// a hostname, and then logs in with it.
+func (a *App) showAuthKeyLogin(ctx context.Context) {
+	tagsRow := adw.NewEntryRow()
+	tagsRow.SetTitle("Tags (comma-separated, optional)")
+
+	hostnameRow := adw.NewEntryRow()
+	hostnameRow.SetTitle("Hostname (optional)")
+
+	Prompt{
+		Heading:     "Log In with Auth Key",
+		Body:        "Auth keys can be generated in the Keys section of the admin console.",
+		Placeholder: "tskey-auth-...",
+		Purpose:     gtk.InputPurposePassword,
+		Responses: []PromptResponse{
+			{ID: "cancel", Label: "_Cancel"},
+			{ID: "login", Label: "_Log In", Appearance: adw.ResponseSuggested, Default: true},
+		},
+		Extra: func() gtk.Widgetter {
+			list := gtk.NewListBox()
+			list.AddCSSClass("boxed-list")
+			list.SetSelectionMode(gtk.SelectionNone)
+			list.Append(tagsRow)
+			list.Append(hostnameRow)
+			return list
+		},
+	}.Show(a, "", func(response, key string) {
+		key = strings.TrimSpace(key)
+		if (response != "login") || (key == "") {
+			return
+		}
+
+		tags := splitList(tagsRow.Text())
+		hostname := strings.TrimSpace(hostnameRow.Text())
+		go a.loginWithAuthKey(ctx, key, tags, hostname)
+	})
+}
+
+func (a *App) loginWithAuthKey(ctx context.Context, key string, tags []string, hostname string) {
+	ctx, cancel := context.WithTimeout(ctx, authKeyLoginTimeout)
+	defer cancel()
+
+	err := tsutil.LoginWithAuthKey(ctx, key, tags, hostname)
+	if err != nil {
+		a.reportError("Login failed", err)
+		return
+	}
+
+	<-a.poller.Poll()
+	for {
+		select {
+		case <-ctx.Done():
+			a.reportError("Login failed", errors.New("timed out waiting to connect"))
+			return
+		case status := <-a.poller.NextIPN():
+			if status.Online() {
+				return
+			}
+		}
+	}
+}
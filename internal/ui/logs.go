@@ -0,0 +1,243 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"deedles.dev/trayscale/internal/gutil"
+	"deedles.dev/trayscale/internal/listmodels"
+	"deedles.dev/trayscale/internal/logbuf"
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/core/gioutil"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+//go:embed logs.ui
+var logsXML string
+
+// logLevels are the levels that can be selected in the logs window,
+// in the same order as they are listed there.
+var logLevels = []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError}
+
+// initLogging makes the default logger keep recent messages in a.logs
+// as well as writing them to stderr.
+func (a *App) initLogging(level slog.Level, json bool) {
+	opts := slog.HandlerOptions{Level: level}
+
+	var h slog.Handler = slog.NewTextHandler(os.Stderr, &opts)
+	if json {
+		h = slog.NewJSONHandler(os.Stderr, &opts)
+	}
+	slog.SetDefault(slog.New(logbuf.NewHandler(&a.logs, h)))
+}
+
+// initLoggingOptions adds command-line options for configuring logging
+// to the application. The returned function applies them and returns
+// an exit code if the options are invalid or -1 otherwise.
+func (a *App) initLoggingOptions() func(options *glib.VariantDict) int {
+	a.app.AddMainOption("log-level", 0, glib.OptionFlagNone, glib.OptionArgString, "Minimum level of messages to log: debug, info, warn, or error", "LEVEL")
+	a.app.AddMainOption("log-json", 0, glib.OptionFlagNone, glib.OptionArgNone, "Log messages as JSON", "")
+
+	return func(options *glib.VariantDict) int {
+		level := slog.LevelInfo
+		if v := options.LookupValue("log-level", glib.NewVariantType("s")); v != nil {
+			err := level.UnmarshalText([]byte(v.String()))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "invalid log level %q\n", v.String())
+				return 2
+			}
+		}
+
+		a.initLogging(level, options.Contains("log-json"))
+		return -1
+	}
+}
+
+// showLogs shows the logs window, creating it if necessary.
+func (a *App) showLogs() {
+	if a.logsWindow == nil {
+		a.logsWindow = NewLogsWindow(a)
+	}
+	a.logsWindow.LogsWindow.Present()
+}
+
+// LogsWindow shows recent log messages.
+type LogsWindow struct {
+	app       *App
+	model     *gioutil.ListModel[logbuf.Entry]
+	filter    *gtk.CustomFilter
+	following bool
+
+	LogsWindow    *adw.Window
+	ToastOverlay  *adw.ToastOverlay
+	LogsScroller  *gtk.ScrolledWindow
+	LogsView      *gtk.ListView
+	SearchEntry   *gtk.SearchEntry
+	LevelDropDown *gtk.DropDown
+}
+
+func NewLogsWindow(a *App) *LogsWindow {
+	win := LogsWindow{app: a}
+	gutil.FillFromUI(&win, logsXML)
+	win.init()
+	return &win
+}
+
+func (win *LogsWindow) init() {
+	win.model = gioutil.NewListModel[logbuf.Entry]()
+	for _, e := range win.app.logs.Entries() {
+		win.model.Append(e)
+	}
+
+	win.filter = gtk.NewCustomFilter(func(obj *glib.Object) bool {
+		e := listmodels.Convert[logbuf.Entry](obj)
+		return e.Matches(win.level(), win.SearchEntry.Text())
+	})
+	win.LevelDropDown.NotifyProperty("selected", win.refilter)
+	win.SearchEntry.ConnectSearchChanged(win.refilter)
+
+	filtered := gtk.NewFilterListModel(win.model, &win.filter.Filter)
+	win.LogsView.SetFactory(&newLogEntryFactory().ListItemFactory)
+	win.LogsView.SetModel(gtk.NewNoSelection(filtered))
+
+	win.following = true
+	vadj := win.LogsScroller.VAdjustment()
+	vadj.ConnectValueChanged(func() {
+		win.following = vadj.Value() >= vadj.Upper()-vadj.PageSize()-1
+	})
+	filtered.ConnectItemsChanged(func(position, removed, added uint) {
+		if win.following && (filtered.NItems() > 0) {
+			win.LogsView.ScrollTo(filtered.NItems()-1, gtk.ListScrollNone, nil)
+		}
+	})
+
+	stop := win.app.logs.Watch(func(e logbuf.Entry) {
+		glib.IdleAdd(func() {
+			win.model.Append(e)
+			if win.model.Len() > logbuf.DefaultLimit {
+				win.model.Remove(0)
+			}
+		})
+	})
+	win.LogsWindow.ConnectCloseRequest(func() bool {
+		stop()
+		win.app.logsWindow = nil
+		return false
+	})
+
+	actions := gio.NewSimpleActionGroup()
+
+	copyAction := gio.NewSimpleAction("copy", nil)
+	copyAction.ConnectActivate(func(p *glib.Variant) { win.copy(filtered) })
+	actions.AddAction(copyAction)
+
+	saveAction := gio.NewSimpleAction("save", nil)
+	saveAction.ConnectActivate(func(p *glib.Variant) { win.save() })
+	actions.AddAction(saveAction)
+
+	clearAction := gio.NewSimpleAction("clear", nil)
+	clearAction.ConnectActivate(func(p *glib.Variant) {
+		win.app.logs.Clear()
+		win.model.Splice(0, win.model.Len())
+	})
+	actions.AddAction(clearAction)
+
+	win.LogsWindow.InsertActionGroup("logs", actions)
+}
+
+func (win *LogsWindow) level() slog.Level {
+	i := int(win.LevelDropDown.Selected())
+	if i < 0 || i >= len(logLevels) {
+		return slog.LevelDebug
+	}
+	return logLevels[i]
+}
+
+func (win *LogsWindow) refilter() {
+	win.filter.Changed(gtk.FilterChangeDifferent)
+}
+
+func (win *LogsWindow) toast(msg string) {
+	toast := adw.NewToast(msg)
+	toast.SetTimeout(3)
+	win.ToastOverlay.AddToast(toast)
+}
+
+func (win *LogsWindow) copy(shown *gtk.FilterListModel) {
+	var sb strings.Builder
+	for _, e := range listmodels.Values[logbuf.Entry](shown) {
+		sb.WriteString(e.String())
+		sb.WriteByte('\n')
+	}
+
+	win.app.clip(glib.NewValue(sb.String()))
+	win.toast("Copied messages to clipboard")
+}
+
+func (win *LogsWindow) save() {
+	var buf bytes.Buffer
+	for _, e := range win.app.logs.Entries() {
+		buf.WriteString(e.String())
+		buf.WriteByte('\n')
+	}
+
+	fd := gtk.NewFileDialog()
+	fd.SetModal(true)
+	fd.SetInitialName(time.Now().Format("trayscale-20060102-150405.log"))
+	fd.Save(context.TODO(), &win.LogsWindow.Window, func(res gio.AsyncResulter) {
+		f, err := fd.SaveFinish(res)
+		if err != nil {
+			if !gutil.ErrHasCode(err, int(gtk.DialogErrorDismissed)) {
+				win.app.reportError("Failed to save logs", err)
+			}
+			return
+		}
+
+		err = replaceFile(context.TODO(), f, bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			win.app.reportError("Failed to save logs", err)
+			return
+		}
+		win.toast("Saved logs")
+	})
+}
+
+func newLogEntryFactory() *gtk.SignalListItemFactory {
+	factory := gtk.NewSignalListItemFactory()
+	factory.ConnectSetup(func(obj *glib.Object) {
+		label := gtk.NewLabel("")
+		label.SetXAlign(0)
+		label.SetSelectable(true)
+		label.SetWrap(true)
+		label.SetMarginStart(6)
+		label.SetMarginEnd(6)
+		obj.Cast().(*gtk.ListItem).SetChild(label)
+	})
+	factory.ConnectBind(func(obj *glib.Object) {
+		item := obj.Cast().(*gtk.ListItem)
+		e := listmodels.Convert[logbuf.Entry](item.Item())
+
+		label := item.Child().(*gtk.Label)
+		label.SetText(e.String())
+		for _, class := range []string{"dim-label", "warning", "error"} {
+			label.RemoveCSSClass(class)
+		}
+		switch {
+		case e.Level >= slog.LevelError:
+			label.AddCSSClass("error")
+		case e.Level >= slog.LevelWarn:
+			label.AddCSSClass("warning")
+		case e.Level < slog.LevelInfo:
+			label.AddCSSClass("dim-label")
+		}
+	})
+	return factory
+}
@@ -3,16 +3,15 @@ package ui
 import (
 	"context"
 	_ "embed"
-	"log/slog"
-	"slices"
+	"fmt"
 	"strings"
 	"time"
 
 	"deedles.dev/trayscale/internal/gutil"
 	"deedles.dev/trayscale/internal/listmodels"
-	"deedles.dev/trayscale/internal/metadata"
 	"deedles.dev/trayscale/internal/tsutil"
 	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/core/gioutil"
 	"github.com/diamondburned/gotk4/pkg/gio/v2"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
@@ -34,6 +33,7 @@ type MainWindow struct {
 	ToastOverlay    *adw.ToastOverlay
 	SplitView       *adw.NavigationSplitView
 	StatusSwitch    *gtk.Switch
+	StatusBusyBin   *adw.Bin
 	MainMenuButton  *gtk.MenuButton
 	PeersList       *gtk.ListBox
 	PeersStack      *adw.ViewStack
@@ -41,13 +41,16 @@ type MainWindow struct {
 	ProfileDropDown *gtk.DropDown
 	PageMenuButton  *gtk.MenuButton
 
+	ControlServerSection *gio.Menu
+
 	pages map[string]Page
 
-	profiles              []ipn.LoginProfile
-	profileModel          *gtk.StringList
-	profileSortModel      *gtk.SortListModel
-	updatingProfiles      bool
-	activeProfileID       ipn.ProfileID
+	profileModel     *gioutil.ListModel[profileItem]
+	profileSortModel *gtk.SortListModel
+	updatingProfiles bool
+	activeProfileID  ipn.ProfileID
+
+	controlServerName string
 }
 
 func NewMainWindow(app *App) *MainWindow {
@@ -124,32 +127,19 @@ func NewMainWindow(app *App) *MainWindow {
 		win.PeersStack.SetVisibleChildName(name)
 	})
 
-	win.profileModel = gtk.NewStringList(nil)
-	win.profileSortModel = gtk.NewSortListModel(win.profileModel, &stringListSorter.Sorter)
+	win.profileModel = gioutil.NewListModel[profileItem]()
+	win.profileSortModel = gtk.NewSortListModel(win.profileModel, &profileSorter.Sorter)
 	win.ProfileDropDown.SetModel(win.profileSortModel)
+	win.ProfileDropDown.SetFactory(&newProfileFactory(false).ListItemFactory)
+	win.ProfileDropDown.SetListFactory(&newProfileFactory(true).ListItemFactory)
 
-	win.StatusSwitch.ConnectStateSet(func(s bool) bool {
-		if s == win.StatusSwitch.State() {
-			return false
-		}
-
-		// TODO: Handle this, and other switches, asynchrounously instead
-		// of freezing the entire UI.
-		ctx, cancel := context.WithTimeout(context.TODO(), 30*time.Second)
-		defer cancel()
-
-		f := app.stopTS
+	statusBusy := newBusyIndicator()
+	win.StatusBusyBin.SetChild(statusBusy)
+	app.connectAsyncSwitch(win.StatusSwitch, statusBusy, "Failed to change Tailscale status", func(ctx context.Context, s bool) error {
 		if s {
-			f = app.startTS
-		}
-
-		err := f(ctx)
-		if err != nil {
-			slog.Error("set Tailscale status", "err", err)
-			win.StatusSwitch.SetActive(!s)
-			return true
+			return app.startTS(ctx)
 		}
-		return true
+		return app.stopTS(ctx)
 	})
 
 	win.ProfileDropDown.NotifyProperty("selected-item", func() {
@@ -157,21 +147,11 @@ func NewMainWindow(app *App) *MainWindow {
 			return
 		}
 
-		obj, ok := win.ProfileDropDown.SelectedItem().Cast().(*gtk.StringObject)
-		if !ok {
+		obj := win.ProfileDropDown.SelectedItem()
+		if obj == nil {
 			return
 		}
-
-		item := obj.String()
-		index := slices.IndexFunc(win.profiles, func(p ipn.LoginProfile) bool {
-			// TODO: Find a reasonable way to do this by profile ID instead.
-			return p.Name == item
-		})
-		if index < 0 {
-			slog.Error("selected unknown profile", "name", item)
-			return
-		}
-		profile := win.profiles[index]
+		profile := listmodels.Convert[profileItem](obj)
 
 		if profile.ID == win.activeProfileID {
 			return
@@ -182,7 +162,7 @@ func NewMainWindow(app *App) *MainWindow {
 
 		err := tsutil.SwitchProfile(ctx, profile.ID)
 		if err != nil {
-			slog.Error("failed to switch profiles", "err", err, "id", profile.ID, "name", profile.Name)
+			app.reportError(fmt.Sprintf("Failed to switch to %v", profile.Name), err)
 			return
 		}
 		<-app.poller.Poll()
@@ -222,6 +202,7 @@ func (win *MainWindow) Update(status tsutil.Status) {
 		win.StatusSwitch.SetState(online)
 		win.StatusSwitch.SetActive(online)
 
+		win.updateControlServer(status)
 		win.updatePeers(status)
 
 	case *tsutil.FileStatus:
@@ -285,27 +266,53 @@ func (win *MainWindow) updateProfiles(status *tsutil.ProfileStatus) {
 	win.updatingProfiles = true
 	defer func() { win.updatingProfiles = false }()
 
-	win.profiles = status.Profiles
 	win.activeProfileID = status.Profile.ID
 
-	listmodels.UpdateStrings(win.profileModel, func(yield func(string) bool) {
+	listmodels.Update(win.profileModel, func(yield func(profileItem) bool) {
 		for _, profile := range status.Profiles {
-			name := profile.Name
-			if metadata.Private {
-				name = "profile@example.com"
-			}
-			if !yield(name) {
+			if !yield(newProfileItem(profile)) {
 				return
 			}
 		}
 	})
 
-	profileIndex, ok := listmodels.Index(win.profileSortModel, func(obj *gtk.StringObject) bool {
-		return obj.String() == status.Profile.Name
+	// A new profile that hasn't been logged into yet isn't in the list.
+	profileIndex, ok := listmodels.Index(win.profileSortModel, func(item profileItem) bool {
+		return item.ID == status.Profile.ID
 	})
-	if ok {
-		win.ProfileDropDown.SetSelected(uint(profileIndex))
+	if !ok {
+		profileIndex = gtk.InvalidListPosition
 	}
+	win.ProfileDropDown.SetSelected(profileIndex)
+}
+
+// updateControlServer shows the name of the current control server in
+// the main menu.
+func (win *MainWindow) updateControlServer(status *tsutil.IPNStatus) {
+	if !status.Prefs.Valid() {
+		return
+	}
+
+	name := win.app.controlServerName(status.Prefs.ControlURL())
+	if name == win.controlServerName {
+		return
+	}
+	win.controlServerName = name
+
+	// Underscores would otherwise be interpreted as mnemonics.
+	label := fmt.Sprintf("Control _Server: %v", strings.ReplaceAll(name, "_", "__"))
+	win.ControlServerSection.RemoveAll()
+	win.ControlServerSection.Append(label, "app.change_control_server")
+}
+
+// ShowSelf selects the page for this machine, if there is one.
+func (win *MainWindow) ShowSelf() {
+	if _, ok := win.pages["self"]; !ok {
+		return
+	}
+
+	// The self page is always sorted first.
+	win.PeersList.SelectRow(win.PeersList.RowAtIndex(0))
 }
 
 func (win *MainWindow) Toast(msg string) *adw.Toast {
@@ -169,13 +169,11 @@ func (page *MullvadPage) getExitNodeRow(peer tailcfg.NodeView) *mullvadExitNodeR
 	sw := row.ActivatableWidget().(*gtk.Switch)
 	sw.SetMarginTop(12)
 	sw.SetMarginBottom(12)
-	sw.ConnectStateSet(func(s bool) bool {
-		if s == sw.State() {
-			return false
-		}
-
+	busy := newBusyIndicator()
+	row.AddSuffix(busy)
+	page.app.connectAsyncSwitch(sw, busy, "Failed to set exit node", func(ctx context.Context, s bool) error {
 		if s {
-			err := tsutil.AdvertiseExitNode(context.TODO(), false)
+			err := tsutil.AdvertiseExitNode(ctx, false)
 			if err != nil {
 				slog.Error("disable exit node advertisement", "err", err)
 				// Continue anyways.
@@ -186,13 +184,7 @@ func (page *MullvadPage) getExitNodeRow(peer tailcfg.NodeView) *mullvadExitNodeR
 		if s {
 			node = peer.StableID()
 		}
-		err := tsutil.ExitNode(context.TODO(), node)
-		if err != nil {
-			slog.Error("set exit node", "err", err)
-			sw.SetActive(!s)
-			return true
-		}
-		return true
+		return tsutil.ExitNode(ctx, node)
 	})
 
 	page.getLocationRow(info.Location()).AddRow(row)
@@ -0,0 +1,164 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"deedles.dev/trayscale/internal/netreport"
+	"deedles.dev/trayscale/internal/tsutil"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+)
+
+const (
+	// netcheckTimeout is how long a netcheck may take before it is
+	// given up on.
+	netcheckTimeout = time.Minute
+
+	// linkChangeDelay is how long to wait after the network changes
+	// before running a netcheck so that a burst of changes, such as
+	// when waking from sleep, only results in a single run.
+	linkChangeDelay = 5
+)
+
+func netcheckHistoryPath() string {
+	return filepath.Join(glib.GetUserDataDir(), "trayscale", "netcheck-history.json")
+}
+
+func (a *App) loadNetCheckHistory() {
+	history, err := netreport.LoadHistory(netcheckHistoryPath())
+	if err != nil {
+		slog.Error("load netcheck history", "err", err)
+		return
+	}
+	a.netcheckHistory = history
+}
+
+func (a *App) saveNetCheckHistory() {
+	err := netreport.SaveHistory(netcheckHistoryPath(), a.netcheckHistory)
+	if err != nil {
+		slog.Error("save netcheck history", "err", err)
+	}
+}
+
+func (a *App) clearNetCheckHistory() {
+	a.netcheckHistory = nil
+	a.saveNetCheckHistory()
+}
+
+// runNetCheck runs a netcheck in the background, adds it to the
+// history, and shows it on the self page. If a netcheck is already
+// running, it does nothing.
+func (a *App) runNetCheck(trigger netreport.Trigger) {
+	if a.netcheckCancel != nil {
+		return
+	}
+
+	var report netreport.Report
+	a.netcheckCancel = asyncAction{
+		Timeout: netcheckTimeout,
+		Run: func(ctx context.Context) error {
+			r, dm, err := tsutil.NetCheck(ctx, true)
+			if err != nil {
+				return err
+			}
+			report = netreport.New(r, dm)
+			return nil
+		},
+		Busy: func(busy bool) {
+			if !busy {
+				a.netcheckCancel = nil
+			}
+			self := a.selfPage()
+			if self == nil {
+				return
+			}
+			if !busy {
+				self.setNetCheckBusy(nil)
+				return
+			}
+			// Busy is called before Start returns, so cancelNetCheck is
+			// used to find the cancel function when it's needed.
+			self.setNetCheckBusy(a.cancelNetCheck)
+		},
+		Done: func(err error) {
+			if err != nil {
+				if trigger == netreport.TriggerManual && !errors.Is(err, context.Canceled) {
+					a.reportError("Network check failed", err)
+					return
+				}
+				slog.Error("netcheck", "trigger", trigger, "err", err)
+				return
+			}
+
+			a.netReport = report
+			a.netcheckHistory = netreport.AppendHistory(a.netcheckHistory, report.Summary(trigger), netreport.DefaultHistoryLimit)
+			a.saveNetCheckHistory()
+
+			if self := a.selfPage(); self != nil {
+				self.showNetCheck(report)
+			}
+		},
+	}.Start(a)
+}
+
+// cancelNetCheck cancels the running netcheck, if there is one.
+func (a *App) cancelNetCheck() {
+	if a.netcheckCancel != nil {
+		a.netcheckCancel()
+	}
+}
+
+// selfPage returns the self page, or nil if it isn't being shown.
+func (a *App) selfPage() *SelfPage {
+	if a.win == nil {
+		return nil
+	}
+	self, _ := a.win.pages["self"].(*SelfPage)
+	return self
+}
+
+// scheduleNetCheck starts running netchecks at the interval set in
+// the settings, replacing the previous schedule, if any.
+func (a *App) scheduleNetCheck() {
+	if a.netcheckTimer != 0 {
+		glib.SourceRemove(a.netcheckTimer)
+		a.netcheckTimer = 0
+	}
+	if a.settings == nil {
+		return
+	}
+
+	interval := a.settings.Uint("netcheck-interval")
+	if interval == 0 {
+		return
+	}
+
+	a.netcheckTimer = glib.TimeoutSecondsAdd(interval*60, func() bool {
+		if a.online {
+			a.runNetCheck(netreport.TriggerScheduled)
+		}
+		return true
+	})
+}
+
+// onLinkChange runs a netcheck shortly after a major change to the
+// network if the settings allow it.
+func (a *App) onLinkChange() {
+	if (a.settings != nil) && !a.settings.Boolean("netcheck-on-link-change") {
+		return
+	}
+
+	if a.linkChangeTimer != 0 {
+		glib.SourceRemove(a.linkChangeTimer)
+	}
+	a.linkChangeTimer = glib.TimeoutSecondsAdd(linkChangeDelay, func() bool {
+		a.linkChangeTimer = 0
+		if a.online {
+			a.runNetCheck(netreport.TriggerLinkChange)
+		}
+		return false
+	})
+}
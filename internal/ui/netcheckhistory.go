@@ -0,0 +1,128 @@
+package ui
+
+import (
+	_ "embed"
+	"slices"
+
+	"deedles.dev/trayscale/internal/gutil"
+	"deedles.dev/trayscale/internal/listmodels"
+	"deedles.dev/trayscale/internal/netreport"
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/core/gioutil"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+//go:embed netcheckhistory.ui
+var netcheckHistoryXML string
+
+// NetCheckHistoryDialog shows the stored netcheck history, newest
+// first, highlighting the values that changed since the check before
+// each one.
+type NetCheckHistoryDialog struct {
+	app *App
+
+	Dialog      *adw.Dialog
+	Stack       *gtk.Stack
+	HistoryView *gtk.ColumnView
+	ClearButton *gtk.Button
+}
+
+// netcheckHistoryEntry is a single row of the history table.
+type netcheckHistoryEntry struct {
+	Summary netreport.Summary
+	Changed []netreport.Field
+}
+
+func NewNetCheckHistoryDialog(a *App) *NetCheckHistoryDialog {
+	dialog := NetCheckHistoryDialog{app: a}
+	gutil.FillFromUI(&dialog, netcheckHistoryXML)
+	dialog.init()
+	return &dialog
+}
+
+func (dialog *NetCheckHistoryDialog) init() {
+	history := dialog.app.netcheckHistory
+
+	model := gioutil.NewListModel[netcheckHistoryEntry]()
+	for i, s := range slices.Backward(history) {
+		entry := netcheckHistoryEntry{Summary: s}
+		if i > 0 {
+			entry.Changed = netreport.Changed(history[i-1], s)
+		}
+		model.Append(entry)
+	}
+
+	timeColumn := historyColumn("Time", -1, func(s netreport.Summary) string { return formatTime(s.Time) })
+	timeColumn.SetExpand(true)
+	dialog.HistoryView.AppendColumn(timeColumn)
+	dialog.HistoryView.AppendColumn(historyColumn("Trigger", -1, func(s netreport.Summary) string { return s.Trigger.Name() }))
+	for _, f := range netreport.Fields {
+		dialog.HistoryView.AppendColumn(historyColumn(f.Name(), f, f.Value))
+	}
+	dialog.HistoryView.AppendColumn(historyColumn("DERP Latency", -1, func(s netreport.Summary) string { return formatLatency(s.PreferredDERPLatency) }))
+
+	dialog.HistoryView.SetModel(gtk.NewNoSelection(model))
+	dialog.updateStack(model.Len())
+
+	dialog.ClearButton.SetSensitive(model.Len() > 0)
+	dialog.ClearButton.ConnectClicked(func() {
+		Confirmation{
+			Heading: "Clear History?",
+			Body:    "The results of all previous network checks will be deleted.",
+			Accept:  "_Clear",
+			Reject:  "_Cancel",
+		}.Show(dialog.app, func(accept bool) {
+			if !accept {
+				return
+			}
+
+			dialog.app.clearNetCheckHistory()
+			model.Splice(0, model.Len())
+			dialog.updateStack(0)
+			dialog.ClearButton.SetSensitive(false)
+		})
+	})
+}
+
+func (dialog *NetCheckHistoryDialog) updateStack(n int) {
+	if n == 0 {
+		dialog.Stack.SetVisibleChildName("empty")
+		return
+	}
+	dialog.Stack.SetVisibleChildName("history")
+}
+
+func (dialog *NetCheckHistoryDialog) Present() {
+	dialog.Dialog.Present(gutil.PointerToWidgetter(dialog.app.window()))
+}
+
+// historyColumn creates a column that shows the text returned by text
+// for each entry. If field is negative, the column's cells are never
+// highlighted as changed.
+func historyColumn(title string, field netreport.Field, text func(netreport.Summary) string) *gtk.ColumnViewColumn {
+	factory := gtk.NewSignalListItemFactory()
+	factory.ConnectSetup(func(obj *glib.Object) {
+		label := gtk.NewLabel("")
+		label.SetXAlign(0)
+		obj.Cast().(*gtk.ListItem).SetChild(label)
+	})
+	factory.ConnectBind(func(obj *glib.Object) {
+		item := obj.Cast().(*gtk.ListItem)
+		entry := listmodels.Convert[netcheckHistoryEntry](item.Item())
+
+		label := item.Child().(*gtk.Label)
+		label.SetText(text(entry.Summary))
+		if field >= 0 && slices.Contains(entry.Changed, field) {
+			label.AddCSSClass("accent")
+			label.AddCSSClass("heading")
+		} else {
+			label.RemoveCSSClass("accent")
+			label.RemoveCSSClass("heading")
+		}
+	})
+
+	column := gtk.NewColumnViewColumn(title, &factory.ListItemFactory)
+	column.SetResizable(true)
+	return column
+}
@@ -74,7 +74,8 @@ type PeerPage struct {
 	SendDirButton         *adw.ButtonRow
 	DropTarget            *gtk.DropTarget
 
-	sendFileAction *gio.SimpleAction
+	sendFileAction      *gio.SimpleAction
+	sendClipboardAction *gio.SimpleAction
 
 	addrModel  *gioutil.ListModel[netip.Addr]
 	routeModel *gioutil.ListModel[netip.Prefix]
@@ -129,6 +130,12 @@ func (page *PeerPage) init(a *App, status *tsutil.IPNStatus, peer tailcfg.NodeVi
 	})
 	page.actions.AddAction(page.sendFileAction)
 
+	page.sendClipboardAction = gio.NewSimpleAction("sendClipboard", nil)
+	page.sendClipboardAction.ConnectActivate(func(p *glib.Variant) {
+		a.sendClipboard(context.TODO(), page.peer.StableID(), peerName(page.peer))
+	})
+	page.actions.AddAction(page.sendClipboardAction)
+
 	page.Page.AddController(page.DropTarget)
 	page.DropTarget.SetGTypes([]glib.Type{gio.GTypeFile})
 	page.DropTarget.ConnectDrop(func(val *glib.Value, x, y float64) bool {
@@ -187,7 +194,7 @@ func (page *PeerPage) init(a *App, status *tsutil.IPNStatus, peer tailcfg.NodeVi
 				}))
 				err := tsutil.AdvertiseRoutes(context.TODO(), routes)
 				if err != nil {
-					slog.Error("advertise routes", "err", err)
+					a.reportError("Failed to remove route", err)
 					return
 				}
 			})
@@ -205,13 +212,10 @@ func (page *PeerPage) init(a *App, status *tsutil.IPNStatus, peer tailcfg.NodeVi
 	advertisedRoutesListPlaceholder.SetTitle("No advertised routes.")
 	page.AdvertisedRoutesList.SetPlaceholder(advertisedRoutesListPlaceholder)
 
-	page.ExitNodeRow.ActivatableWidget().(*gtk.Switch).ConnectStateSet(func(s bool) bool {
-		if s == page.ExitNodeRow.ActivatableWidget().(*gtk.Switch).State() {
-			return false
-		}
-
+	id := peer.StableID()
+	a.connectAsyncSwitchRow(page.ExitNodeRow, "Failed to set exit node", func(ctx context.Context, s bool) error {
 		if s {
-			err := tsutil.AdvertiseExitNode(context.TODO(), false)
+			err := tsutil.AdvertiseExitNode(ctx, false)
 			if err != nil {
 				slog.Error("disable exit node advertisement", "err", err)
 				// Continue anyways.
@@ -220,15 +224,9 @@ func (page *PeerPage) init(a *App, status *tsutil.IPNStatus, peer tailcfg.NodeVi
 
 		var node tailcfg.StableNodeID
 		if s {
-			node = page.peer.StableID()
+			node = id
 		}
-		err := tsutil.ExitNode(context.TODO(), node)
-		if err != nil {
-			slog.Error("set exit node", "err", err)
-			page.ExitNodeRow.ActivatableWidget().(*gtk.Switch).SetActive(!s)
-			return true
-		}
-		return true
+		return tsutil.ExitNode(ctx, node)
 	})
 }
 
@@ -259,7 +257,9 @@ func (page *PeerPage) Update(s tsutil.Status) bool {
 		return false
 	}
 
-	page.sendFileAction.SetEnabled(status.FileTargets.Contains(page.peer.StableID()))
+	fileTarget := status.FileTargets.Contains(page.peer.StableID())
+	page.sendFileAction.SetEnabled(fileTarget)
+	page.sendClipboardAction.SetEnabled(fileTarget)
 
 	online := page.peer.Online().Get()
 	exitNodeOption := tsaddr.ContainsExitRoutes(page.peer.AllowedIPs())
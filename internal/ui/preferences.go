@@ -12,12 +12,33 @@ import (
 var preferencesXML string
 
 type PreferencesDialog struct {
-	PreferencesDialog            *adw.PreferencesDialog
-	UseTrayIconRow               *adw.SwitchRow
-	PollingIntervalRow           *adw.SpinRow
-	PollingIntervalAdjustment    *gtk.Adjustment
-	TaildropAutoSaveRow          *adw.SwitchRow
-	TaildropAutoSaveFolderButton *gtk.Button
+	PreferencesDialog             *adw.PreferencesDialog
+	UseTrayIconRow                *adw.SwitchRow
+	TrayIconThemeRow              *adw.ComboRow
+	TrayPrimaryActionRow          *adw.ComboRow
+	TraySecondaryActionRow        *adw.ComboRow
+	PollingIntervalRow            *adw.SpinRow
+	PollingIntervalAdjustment     *gtk.Adjustment
+	NetCheckIntervalAdjustment    *gtk.Adjustment
+	NetCheckOnLinkChangeRow       *adw.SwitchRow
+	TaildropAutoSaveRow           *adw.SwitchRow
+	TaildropAutoSaveFolderButton  *gtk.Button
+	TaildropMaxSizeAdjustment     *gtk.Adjustment
+	TaildropMinFreeAdjustment     *gtk.Adjustment
+	TaildropConfirmRow            *adw.SwitchRow
+	TaildropScanRow               *adw.ExpanderRow
+	TaildropScanSocketRow         *adw.EntryRow
+	TaildropScanActionRow         *adw.ComboRow
+	TaildropConflictPolicyRow     *adw.ComboRow
+	TaildropExtractArchivesRow    *adw.SwitchRow
+	TaildropArchiveFormatRow      *adw.ComboRow
+	TaildropArchivePreScanRow     *adw.SwitchRow
+	TaildropArchiveExcludeRow     *adw.EntryRow
+	TaildropRulesGroup            *adw.PreferencesGroup
+	TaildropHooksGroup            *adw.PreferencesGroup
+	TaildropAddHookButton         *gtk.Button
+	TaildropHookTimeoutAdjustment *gtk.Adjustment
+	TaildropAddRuleButton         *gtk.Button
 }
 
 func NewPreferencesDialog() *PreferencesDialog {
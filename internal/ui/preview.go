@@ -0,0 +1,152 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log/slog"
+
+	"deedles.dev/trayscale/internal/preview"
+	"deedles.dev/trayscale/internal/tsutil"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/inhies/go-bytesize"
+	"tailscale.com/client/tailscale/apitype"
+)
+
+// previewImageSize is the size of images shown in the preview dialog.
+const previewImageSize = 480
+
+func previewKey(file apitype.WaitingFile) preview.Key {
+	return preview.Key{Name: file.Name, Size: file.Size}
+}
+
+// filePreview calls done on the GTK thread with a preview of the
+// waiting file. Previews are generated in the background from the
+// beginning of the file, which is left waiting, and are cached so that
+// each file is only read once.
+func (a *App) filePreview(file apitype.WaitingFile, done func(preview.Preview)) {
+	key := previewKey(file)
+	if p, ok := a.previews.Get(key); ok {
+		done(p)
+		return
+	}
+
+	go func() {
+		slog := slog.With("filename", file.Name)
+
+		r, size, err := tsutil.GetWaitingFile(context.TODO(), file.Name)
+		if err != nil {
+			slog.Error("get file for preview", "err", err)
+			return
+		}
+		p, err := preview.Generate(r, size)
+		r.Close()
+		if err != nil {
+			// Cache the failure anyway so that the file isn't read again
+			// every time that the list is updated.
+			slog.Warn("generate preview", "err", err)
+		}
+		a.previews.Put(key, p)
+
+		glib.IdleAdd(func() { done(p) })
+	}()
+}
+
+// prunePreviews removes cached previews for files that are no longer
+// waiting.
+func (a *App) prunePreviews(files []apitype.WaitingFile) {
+	keep := make(map[preview.Key]struct{}, len(files))
+	for _, file := range files {
+		keep[previewKey(file)] = struct{}{}
+	}
+	a.previews.Prune(func(key preview.Key) bool {
+		_, ok := keep[key]
+		return ok
+	})
+}
+
+func previewTexture(data []byte) *gdk.Texture {
+	texture, err := gdk.NewTextureFromBytes(glib.NewBytes(data))
+	if err != nil {
+		slog.Error("load preview texture", "err", err)
+		return nil
+	}
+	return texture
+}
+
+// showFilePreview shows a dialog with a larger preview of the waiting
+// file.
+func (a *App) showFilePreview(file apitype.WaitingFile, p preview.Preview) {
+	body := fmt.Sprintf("%v, %v", html.EscapeString(p.ContentType), bytesize.ByteSize(file.Size))
+
+	var extra func() gtk.Widgetter
+	switch p.Kind {
+	case preview.KindImage:
+		body = fmt.Sprintf("%v×%v, %v", p.Width, p.Height, body)
+		extra = func() gtk.Widgetter {
+			picture := gtk.NewPicture()
+			picture.SetSizeRequest(previewImageSize/2, previewImageSize/2)
+			picture.SetContentFit(gtk.ContentFitContain)
+			if texture := previewTexture(p.Thumbnail); texture != nil {
+				picture.SetPaintable(texture)
+			}
+			a.loadPreviewImage(file, picture)
+			return picture
+		}
+
+	case preview.KindText:
+		extra = func() gtk.Widgetter {
+			buf := gtk.NewTextBuffer(nil)
+			buf.SetText(p.Text)
+
+			text := gtk.NewTextViewWithBuffer(buf)
+			text.SetEditable(false)
+			text.SetCursorVisible(false)
+			text.SetMonospace(true)
+			text.SetWrapMode(gtk.WrapWordChar)
+			text.AddCSSClass("code")
+
+			scroll := gtk.NewScrolledWindow()
+			scroll.SetChild(text)
+			scroll.SetMinContentHeight(240)
+			scroll.SetMinContentWidth(320)
+			scroll.AddCSSClass("frame")
+			return scroll
+		}
+		if p.Truncated {
+			body += "\nOnly the beginning of the file is shown."
+		}
+	}
+
+	Info{
+		Heading: file.Name,
+		Body:    body,
+		Extra:   extra,
+	}.Show(a, nil)
+}
+
+// loadPreviewImage replaces the thumbnail shown in picture with a
+// larger version of the image once it has been generated.
+func (a *App) loadPreviewImage(file apitype.WaitingFile, picture *gtk.Picture) {
+	go func() {
+		r, size, err := tsutil.GetWaitingFile(context.TODO(), file.Name)
+		if err != nil {
+			slog.Error("get file for preview", "filename", file.Name, "err", err)
+			return
+		}
+		data, err := preview.Image(r, size, previewImageSize)
+		r.Close()
+		if err != nil {
+			slog.Error("generate preview image", "filename", file.Name, "err", err)
+			return
+		}
+
+		glib.IdleAdd(func() {
+			if texture := previewTexture(data); texture != nil {
+				picture.SetPaintable(texture)
+			}
+		})
+	}()
+}
@@ -0,0 +1,196 @@
+package ui
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"deedles.dev/trayscale/internal/listmodels"
+	"deedles.dev/trayscale/internal/metadata"
+	"deedles.dev/trayscale/internal/redact"
+	"deedles.dev/trayscale/internal/tsutil"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"tailscale.com/ipn"
+)
+
+var profileSorter = gtk.NewCustomSorter(NewObjectComparer(func(p1, p2 profileItem) int {
+	return cmp.Or(
+		cmp.Compare(p1.Name, p2.Name),
+		cmp.Compare(p1.Tailnet, p2.Tailnet),
+		cmp.Compare(p1.ID, p2.ID),
+	)
+}))
+
+// profileItem is the information about a login profile that is shown
+// in the profile list.
+type profileItem struct {
+	ID         ipn.ProfileID
+	Name       string
+	Tailnet    string
+	ControlURL string
+}
+
+func newProfileItem(profile ipn.LoginProfile) profileItem {
+	item := profileItem{
+		ID:         profile.ID,
+		Name:       profile.Name,
+		Tailnet:    profile.NetworkProfile.DisplayNameOrDefault(),
+		ControlURL: cmp.Or(profile.ControlURL, ipn.DefaultControlURL),
+	}
+	if metadata.Private {
+		item.Name = redact.ProfileName
+		item.Tailnet = redact.Tailnet
+		item.ControlURL = redact.ControlURL
+	}
+	return item
+}
+
+// Description returns a short description of the tailnet that the
+// profile belongs to.
+func (item profileItem) Description() string {
+	if item.Tailnet == "" {
+		return item.ControlURL
+	}
+	return fmt.Sprintf("%v · %v", item.Tailnet, item.ControlURL)
+}
+
+// newProfileFactory returns a factory for widgets that show profiles
+// in a drop-down. If detailed is true, the tailnet and control server
+// are shown under the name.
+func newProfileFactory(detailed bool) *gtk.SignalListItemFactory {
+	factory := gtk.NewSignalListItemFactory()
+	factory.ConnectSetup(func(obj *glib.Object) {
+		item := obj.Cast().(*gtk.ListItem)
+
+		name := gtk.NewLabel("")
+		name.SetXAlign(0)
+
+		box := gtk.NewBox(gtk.OrientationVertical, 0)
+		box.Append(name)
+		if detailed {
+			description := gtk.NewLabel("")
+			description.SetXAlign(0)
+			description.AddCSSClass("caption")
+			description.AddCSSClass("dim-label")
+			box.Append(description)
+		}
+
+		item.SetChild(box)
+	})
+	factory.ConnectBind(func(obj *glib.Object) {
+		item := obj.Cast().(*gtk.ListItem)
+		profile := listmodels.Convert[profileItem](item.Item())
+
+		box := item.Child().(*gtk.Box)
+		name := box.FirstChild().(*gtk.Label)
+		name.SetText(profile.Name)
+		if detailed {
+			description := name.NextSibling().(*gtk.Label)
+			description.SetText(profile.Description())
+		}
+	})
+	return factory
+}
+
+// addProfile switches to a new, empty profile and then starts the
+// login process for it.
+func (a *App) addProfile(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	err := tsutil.AddProfile(ctx)
+	if err != nil {
+		a.reportError("Failed to add profile", err)
+		return
+	}
+	<-a.poller.Poll()
+
+	a.app.ActivateAction("login", nil)
+}
+
+func (a *App) logout(ctx context.Context) {
+	Confirmation{
+		Heading: "Log Out?",
+		Body:    "This device will be disconnected from the tailnet until it is logged in again.",
+		Accept:  "_Log Out",
+		Reject:  "_Cancel",
+	}.Show(a, func(accept bool) {
+		if !accept {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+
+		err := tsutil.Logout(ctx)
+		if err != nil {
+			a.reportError("Failed to log out", err)
+			return
+		}
+		<-a.poller.Poll()
+	})
+}
+
+func (a *App) deleteProfile(ctx context.Context) {
+	type selectOption = SelectOption[profileItem]
+
+	getCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, profiles, err := tsutil.GetProfileStatus(getCtx)
+	if err != nil {
+		a.reportError("Failed to get profiles", err)
+		return
+	}
+
+	options := make([]selectOption, 0, len(profiles))
+	for _, profile := range profiles {
+		item := newProfileItem(profile)
+		options = append(options, selectOption{
+			Title:    item.Name,
+			Subtitle: item.Description(),
+			Value:    item,
+		})
+	}
+	if len(options) == 0 {
+		return
+	}
+	slices.SortFunc(options, func(o1, o2 selectOption) int {
+		return cmp.Compare(o1.Title, o2.Title)
+	})
+
+	Select[profileItem]{
+		Heading: "Delete Profile",
+		Body:    "Select a profile to delete.",
+		Options: options,
+	}.Show(a, func(options []selectOption) {
+		if len(options) == 0 {
+			return
+		}
+		item := options[0].Value
+
+		Confirmation{
+			Heading: "Delete Profile?",
+			Body:    fmt.Sprintf("%v will be logged out and removed from this device.", item.Name),
+			Accept:  "_Delete",
+			Reject:  "_Cancel",
+		}.Show(a, func(accept bool) {
+			if !accept {
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+
+			err := tsutil.DeleteProfile(ctx, item.ID)
+			if err != nil {
+				a.reportError("Failed to delete profile", err)
+				return
+			}
+			<-a.poller.Poll()
+		})
+	})
+}
@@ -0,0 +1,130 @@
+package ui
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"deedles.dev/trayscale/internal/autosave"
+	"deedles.dev/trayscale/internal/clamd"
+	"deedles.dev/trayscale/internal/tsutil"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+)
+
+// scanTimeout limits how long clamd may take to come to a verdict
+// after it has received a file.
+const scanTimeout = 5 * time.Minute
+
+// scanSettings are the settings for scanning files before they are
+// auto-saved.
+type scanSettings struct {
+	Enabled    bool
+	Client     clamd.Client
+	Quarantine bool
+}
+
+func (a *App) scanSettings() scanSettings {
+	if a.settings == nil {
+		return scanSettings{}
+	}
+
+	return scanSettings{
+		Enabled: a.settings.Boolean("taildrop-scan"),
+		Client: clamd.Client{
+			Address: cmp.Or(a.settings.String("taildrop-scan-socket"), clamd.DefaultSocket),
+			Timeout: scanTimeout,
+		},
+		Quarantine: a.settings.String("taildrop-scan-action") == "quarantine",
+	}
+}
+
+// quarantineDir returns the directory that infected files are moved
+// into.
+func quarantineDir() string {
+	return filepath.Join(glib.GetUserDataDir(), "trayscale", "quarantine")
+}
+
+// scanWaitingFile streams the waiting file called name to clamd and
+// reports whether it is safe to save. Infected files are quarantined
+// or deleted according to settings. If the file can't be scanned, it
+// is left waiting and reported as unsafe.
+func (a *App) scanWaitingFile(ctx context.Context, settings scanSettings, name string) bool {
+	slog := slog.With("filename", name, "socket", settings.Client.Address)
+
+	r, _, err := tsutil.GetWaitingFile(ctx, name)
+	if err != nil {
+		slog.Error("get file for scanning", "err", err)
+		return false
+	}
+	result, err := settings.Client.Scan(ctx, r)
+	r.Close()
+	if err != nil {
+		a.reportError(fmt.Sprintf("%v could not be scanned and was left waiting", name), err)
+		return false
+	}
+	if !result.Infected {
+		slog.Info("file scanned clean")
+		return true
+	}
+
+	slog.Warn("infected file received", "signature", result.Signature)
+
+	verb := "deleted"
+	if settings.Quarantine {
+		verb = "quarantined"
+		path, err := a.quarantineFile(ctx, name)
+		if err != nil {
+			slog.Error("quarantine file", "err", err)
+			glib.IdleAdd(func() {
+				a.notify("Threat Detected", fmt.Sprintf("%v contains %v and could not be quarantined. It was left waiting.", name, result.Signature))
+			})
+			return false
+		}
+		slog.Info("file quarantined", "path", path)
+	}
+
+	err = a.deleteWaitingFile(ctx, name)
+	if err != nil {
+		verb = "left waiting"
+	}
+	glib.IdleAdd(func() {
+		a.notify("Threat Detected", fmt.Sprintf("%v contains %v and was %v", name, result.Signature, verb))
+	})
+	return false
+}
+
+// quarantineFile copies the waiting file called name into the
+// quarantine directory without any permissions that would allow it to
+// be executed and returns the path that it was written to.
+func (a *App) quarantineFile(ctx context.Context, name string) (string, error) {
+	dir := quarantineDir()
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return "", err
+	}
+
+	r, size, err := tsutil.GetWaitingFile(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	path := autosave.UniquePath(dir, name)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o400)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	_, err = io.CopyN(file, r, size)
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, file.Close()
+}
@@ -4,7 +4,10 @@ import (
 	"cmp"
 	"context"
 	_ "embed"
+	"fmt"
+	"html"
 	"log/slog"
+	"maps"
 	"net/netip"
 	"slices"
 	"strings"
@@ -12,6 +15,8 @@ import (
 
 	"deedles.dev/trayscale/internal/gutil"
 	"deedles.dev/trayscale/internal/listmodels"
+	"deedles.dev/trayscale/internal/netreport"
+	"deedles.dev/trayscale/internal/preview"
 	"deedles.dev/trayscale/internal/tsutil"
 	"deedles.dev/xiter"
 	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
@@ -45,6 +50,7 @@ type SelfPage struct {
 	AdvertisedRoutesList *gtk.ListBox
 	AdvertiseRouteButton *gtk.Button
 	NetCheckGroup        *adw.PreferencesGroup
+	NetCheckHeader       *gtk.Box
 	NetCheckButton       *gtk.Button
 	LastNetCheckRow      *adw.ActionRow
 	LastNetCheck         *gtk.Label
@@ -67,13 +73,27 @@ type SelfPage struct {
 	PreferredDERPRow     *adw.ActionRow
 	PreferredDERP        *gtk.Label
 	DERPLatencies        *adw.ExpanderRow
+	DERPRegionsRow       *adw.ButtonRow
+	NetCheckHistoryRow   *adw.ButtonRow
 	FilesList            *gtk.ListBox
+	SaveFilesButton      *gtk.Button
+	DeleteFilesButton    *gtk.Button
 
 	addrModel  *gioutil.ListModel[netip.Addr]
 	routeModel *gioutil.ListModel[netip.Prefix]
 	fileModel  *gioutil.ListModel[apitype.WaitingFile]
+
+	files         []apitype.WaitingFile
+	selectedFiles map[string]bool
+
+	netReport    netreport.Report
+	latencyRows  rowManager[derpLatency]
+	netcheckBusy *busyIndicator
 }
 
+// derpLatency is the name of a DERP region and its latency.
+type derpLatency = xiter.Pair[string, time.Duration]
+
 func NewSelfPage(a *App, status *tsutil.IPNStatus) *SelfPage {
 	var page SelfPage
 	gutil.FillFromUI(&page, selfPageXML)
@@ -141,7 +161,7 @@ func (page *SelfPage) init(a *App, status *tsutil.IPNStatus) {
 				}))
 				err := tsutil.AdvertiseRoutes(context.TODO(), routes)
 				if err != nil {
-					slog.Error("advertise routes", "err", err)
+					a.reportError("Failed to remove route", err)
 					return
 				}
 			})
@@ -182,7 +202,7 @@ func (page *SelfPage) init(a *App, status *tsutil.IPNStatus) {
 						return
 					}
 
-					go a.saveFile(context.TODO(), file.Name, f)
+					a.saveFileAs(file.Name, f, nil)
 				})
 			})
 
@@ -201,7 +221,7 @@ func (page *SelfPage) init(a *App, status *tsutil.IPNStatus) {
 					if accept {
 						err := tsutil.DeleteWaitingFile(context.TODO(), file.Name)
 						if err != nil {
-							slog.Error("delete file", "err", err)
+							a.reportError(fmt.Sprintf("Failed to delete %v", file.Name), err)
 							return
 						}
 						<-a.poller.Poll()
@@ -209,83 +229,85 @@ func (page *SelfPage) init(a *App, status *tsutil.IPNStatus) {
 				})
 			})
 
+			check := gtk.NewCheckButton()
+			check.SetVAlign(gtk.AlignCenter)
+			check.SetTooltipText("Select")
+			check.SetActive(page.selectedFiles[file.Name])
+			check.ConnectToggled(func() {
+				if check.Active() {
+					page.selectedFiles[file.Name] = true
+				} else {
+					delete(page.selectedFiles, file.Name)
+				}
+				page.updateFileButtons()
+			})
+
+			previewButton := gtk.NewButtonFromIconName("view-reveal-symbolic")
+			previewButton.SetMarginTop(12)
+			previewButton.SetMarginBottom(12)
+			previewButton.SetHasFrame(false)
+			previewButton.SetTooltipText("Preview")
+			previewButton.SetVisible(false)
+
+			thumbnail := gtk.NewImage()
+			thumbnail.SetPixelSize(32)
+			thumbnail.SetVisible(false)
+
 			row := adw.NewActionRow()
+			row.AddPrefix(check)
+			row.AddPrefix(thumbnail)
+			row.SetActivatableWidget(check)
+			row.AddSuffix(previewButton)
 			row.AddSuffix(saveButton)
 			row.AddSuffix(deleteButton)
 			row.SetTitle(file.Name)
 			row.SetSubtitle(bytesize.ByteSize(file.Size).String())
 
+			a.filePreview(file, func(p preview.Preview) {
+				switch p.Kind {
+				case preview.KindImage:
+					if texture := previewTexture(p.Thumbnail); texture != nil {
+						thumbnail.SetFromPaintable(texture)
+						thumbnail.SetVisible(true)
+					}
+				case preview.KindText:
+					if snippet := p.Snippet(60); snippet != "" {
+						row.SetSubtitle(fmt.Sprintf("%v — %v", bytesize.ByteSize(file.Size), html.EscapeString(snippet)))
+					}
+				default:
+					return
+				}
+
+				previewButton.SetVisible(true)
+				previewButton.ConnectClicked(func() { a.showFilePreview(file, p) })
+			})
+
 			return row
 		},
 	)
 
+	page.selectedFiles = make(map[string]bool)
+	page.SaveFilesButton.ConnectClicked(page.saveFiles)
+	page.DeleteFilesButton.ConnectClicked(page.deleteFiles)
+
 	filesListPlaceholder := adw.NewActionRow()
 	filesListPlaceholder.SetTitle("No incoming files.")
 	page.FilesList.SetPlaceholder(filesListPlaceholder)
 
-	page.AdvertiseExitNodeRow.ActivatableWidget().(*gtk.Switch).ConnectStateSet(func(s bool) bool {
-		if s == page.AdvertiseExitNodeRow.ActivatableWidget().(*gtk.Switch).State() {
-			return false
-		}
-
+	a.connectAsyncSwitchRow(page.AdvertiseExitNodeRow, "Failed to change exit node advertisement", func(ctx context.Context, s bool) error {
 		if s {
-			err := tsutil.ExitNode(context.TODO(), "")
+			err := tsutil.ExitNode(ctx, "")
 			if err != nil {
 				slog.Error("disable existing exit node", "err", err)
 				// Continue anyways.
 			}
 		}
 
-		err := tsutil.AdvertiseExitNode(context.TODO(), s)
-		if err != nil {
-			slog.Error("advertise exit node", "err", err)
-			page.AdvertiseExitNodeRow.ActivatableWidget().(*gtk.Switch).SetActive(!s)
-			return true
-		}
-		return true
-	})
-
-	page.AllowLANAccessRow.ActivatableWidget().(*gtk.Switch).ConnectStateSet(func(s bool) bool {
-		if s == page.AllowLANAccessRow.ActivatableWidget().(*gtk.Switch).State() {
-			return false
-		}
-
-		err := tsutil.AllowLANAccess(context.TODO(), s)
-		if err != nil {
-			slog.Error("allow LAN access", "err", err)
-			page.AllowLANAccessRow.ActivatableWidget().(*gtk.Switch).SetActive(!s)
-			return true
-		}
-		return true
-	})
-
-	page.AcceptRoutesRow.ActivatableWidget().(*gtk.Switch).ConnectStateSet(func(s bool) bool {
-		if s == page.AcceptRoutesRow.ActivatableWidget().(*gtk.Switch).State() {
-			return false
-		}
-
-		err := tsutil.AcceptRoutes(context.TODO(), s)
-		if err != nil {
-			slog.Error("accept routes", "err", err)
-			page.AcceptRoutesRow.ActivatableWidget().(*gtk.Switch).SetActive(!s)
-			return true
-		}
-		return true
-	})
-
-	page.AcceptDNSRow.ActivatableWidget().(*gtk.Switch).ConnectStateSet(func(s bool) bool {
-		if s == page.AcceptDNSRow.ActivatableWidget().(*gtk.Switch).State() {
-			return false
-		}
-
-		err := tsutil.AcceptDNS(context.TODO(), s)
-		if err != nil {
-			slog.Error("accept DNS", "err", err)
-			page.AcceptDNSRow.ActivatableWidget().(*gtk.Switch).SetActive(!s)
-			return true
-		}
-		return true
+		return tsutil.AdvertiseExitNode(ctx, s)
 	})
+	a.connectAsyncSwitchRow(page.AllowLANAccessRow, "Failed to change LAN access", tsutil.AllowLANAccess)
+	a.connectAsyncSwitchRow(page.AcceptRoutesRow, "Failed to change route acceptance", tsutil.AcceptRoutes)
+	a.connectAsyncSwitchRow(page.AcceptDNSRow, "Failed to change DNS setting", tsutil.AcceptDNS)
 
 	page.AdvertiseRouteButton.ConnectClicked(func() {
 		Prompt{
@@ -302,13 +324,13 @@ func (page *SelfPage) init(a *App, status *tsutil.IPNStatus) {
 
 			p, err := netip.ParsePrefix(val)
 			if err != nil {
-				slog.Error("parse prefix", "err", err)
+				a.reportError("Invalid IP prefix", err)
 				return
 			}
 
 			prefs, err := tsutil.Prefs(context.TODO())
 			if err != nil {
-				slog.Error("get prefs", "err", err)
+				a.reportError("Failed to add route", err)
 				return
 			}
 
@@ -317,25 +339,24 @@ func (page *SelfPage) init(a *App, status *tsutil.IPNStatus) {
 				append(prefs.AdvertiseRoutes, p),
 			)
 			if err != nil {
-				slog.Error("advertise routes", "err", err)
+				a.reportError("Failed to add route", err)
 				return
 			}
 		})
 	})
 
-	type latencyEntry = xiter.Pair[string, time.Duration]
-	latencyRows := rowManager[latencyEntry]{
+	page.latencyRows = rowManager[derpLatency]{
 		Parent: rowAdderParent{page.DERPLatencies},
-		New: func(lat latencyEntry) row[latencyEntry] {
+		New: func(lat derpLatency) row[derpLatency] {
 			label := gtk.NewLabel(lat.V2.String())
 
 			row := adw.NewActionRow()
 			row.SetTitle(lat.V1)
 			row.AddSuffix(label)
 
-			return &simpleRow[latencyEntry]{
+			return &simpleRow[derpLatency]{
 				W: row,
-				U: func(lat latencyEntry) {
+				U: func(lat derpLatency) {
 					label.SetText(lat.V2.String())
 					row.SetTitle(lat.V1)
 				},
@@ -343,49 +364,85 @@ func (page *SelfPage) init(a *App, status *tsutil.IPNStatus) {
 		},
 	}
 
+	page.DERPRegionsRow.ConnectActivated(func() {
+		NewDERPDialog(a, page.netReport).Present()
+	})
+	page.NetCheckHistoryRow.ConnectActivated(func() {
+		NewNetCheckHistoryDialog(a).Present()
+	})
+
+	page.netcheckBusy = newBusyIndicator()
+	page.NetCheckHeader.Prepend(page.netcheckBusy)
+	page.setNetCheckBusy(a.netcheckCancel)
 	page.NetCheckButton.ConnectClicked(func() {
-		r, dm, err := tsutil.NetCheck(context.TODO(), true)
-		if err != nil {
-			slog.Error("netcheck", "err", err)
-			return
-		}
+		a.runNetCheck(netreport.TriggerManual)
+	})
+	if a.netReport.Report != nil {
+		page.showNetCheck(a.netReport)
+	}
+}
 
-		page.LastNetCheck.SetText(formatTime(time.Now()))
-		page.UDPRow.SetVisible(true)
-		page.UDP.SetFromGIcon(boolIcon(r.UDP))
-		page.IPv4Row.SetVisible(true)
-		page.IPv4Icon.SetVisible(!r.IPv4)
-		page.IPv4Icon.SetFromGIcon(boolIcon(r.IPv4))
-		page.IPv4Addr.SetVisible(r.IPv4)
-		page.IPv4Addr.SetText(r.GlobalV4.String())
-		page.IPv6Row.SetVisible(true)
-		page.IPv6Icon.SetVisible(!r.IPv6)
-		page.IPv6Icon.SetFromGIcon(boolIcon(r.IPv6))
-		page.IPv6Addr.SetVisible(r.IPv6)
-		page.IPv6Addr.SetText(r.GlobalV6.String())
-		page.UPnPRow.SetVisible(true)
-		page.UPnP.SetFromGIcon(optBoolIcon(r.UPnP))
-		page.PMPRow.SetVisible(true)
-		page.PMP.SetFromGIcon(optBoolIcon(r.PMP))
-		page.PCPRow.SetVisible(true)
-		page.PCP.SetFromGIcon(optBoolIcon(r.PCP))
-		page.CaptivePortalRow.SetVisible(true)
-		page.CaptivePortal.SetFromGIcon(optBoolIcon(r.CaptivePortal))
-		page.PreferredDERPRow.SetVisible(true)
-		page.PreferredDERP.SetText(dm.Regions[r.PreferredDERP].RegionName)
-
-		page.DERPLatencies.SetVisible(true)
-		namedLats := func(yield func(latencyEntry) bool) {
-			for id, latency := range r.RegionLatency {
-				named := xiter.P(dm.Regions[id].RegionName, latency)
-				if !yield(named) {
-					return
-				}
+// setNetCheckBusy shows whether or not a netcheck is running. If one
+// is, cancel is non-nil and cancels it.
+func (page *SelfPage) setNetCheckBusy(cancel func()) {
+	page.netcheckBusy.SetBusy(cancel != nil, cancel)
+	page.NetCheckButton.SetSensitive(cancel == nil)
+}
+
+// showNetCheck shows the results of a netcheck.
+func (page *SelfPage) showNetCheck(report netreport.Report) {
+	page.netReport = report
+	r, dm := report.Report, report.DERPMap
+
+	page.LastNetCheck.SetText(formatTime(report.Time))
+	page.UDPRow.SetVisible(true)
+	page.UDP.SetFromGIcon(boolIcon(r.UDP))
+	page.IPv4Row.SetVisible(true)
+	page.IPv4Icon.SetVisible(!r.IPv4)
+	page.IPv4Icon.SetFromGIcon(boolIcon(r.IPv4))
+	page.IPv4Addr.SetVisible(r.IPv4)
+	page.IPv4Addr.SetText(r.GlobalV4.String())
+	page.IPv6Row.SetVisible(true)
+	page.IPv6Icon.SetVisible(!r.IPv6)
+	page.IPv6Icon.SetFromGIcon(boolIcon(r.IPv6))
+	page.IPv6Addr.SetVisible(r.IPv6)
+	page.IPv6Addr.SetText(r.GlobalV6.String())
+	page.UPnPRow.SetVisible(true)
+	page.UPnP.SetFromGIcon(optBoolIcon(r.UPnP))
+	page.PMPRow.SetVisible(true)
+	page.PMP.SetFromGIcon(optBoolIcon(r.PMP))
+	page.PCPRow.SetVisible(true)
+	page.PCP.SetFromGIcon(optBoolIcon(r.PCP))
+	page.CaptivePortalRow.SetVisible(true)
+	page.CaptivePortal.SetFromGIcon(optBoolIcon(r.CaptivePortal))
+	page.PreferredDERPRow.SetVisible(true)
+	page.PreferredDERP.SetText(cmp.Or(derpRegionName(dm, r.PreferredDERP), "None"))
+
+	page.DERPLatencies.SetVisible(true)
+	namedLats := func(yield func(derpLatency) bool) {
+		for id, latency := range r.RegionLatency {
+			named := xiter.P(cmp.Or(derpRegionName(dm, id), fmt.Sprintf("Region %v", id)), latency)
+			if !yield(named) {
+				return
 			}
 		}
-		sortedLats := slices.SortedFunc(namedLats, func(p1, p2 latencyEntry) int { return cmp.Compare(p1.V2, p2.V2) })
-		latencyRows.Update(sortedLats)
-	})
+	}
+	sortedLats := slices.SortedFunc(namedLats, func(p1, p2 derpLatency) int { return cmp.Compare(p1.V2, p2.V2) })
+	page.latencyRows.Update(sortedLats)
+
+	page.DERPRegionsRow.SetVisible(true)
+}
+
+// derpRegionName returns the name of the DERP region with the given ID
+// in dm, or an empty string if there is no such region.
+func derpRegionName(dm *tailcfg.DERPMap, id int) string {
+	if dm == nil {
+		return ""
+	}
+	if region := dm.Regions[id]; region != nil {
+		return region.RegionName
+	}
+	return ""
 }
 
 func (page *SelfPage) Widget() gtk.Widgetter {
@@ -452,6 +509,78 @@ func (page *SelfPage) UpdateIPN(status *tsutil.IPNStatus) bool {
 }
 
 func (page *SelfPage) UpdateFiles(status *tsutil.FileStatus) bool {
+	page.files = status.Files
+	page.app.prunePreviews(page.files)
+	maps.DeleteFunc(page.selectedFiles, func(name string, _ bool) bool {
+		return !slices.ContainsFunc(page.files, func(f apitype.WaitingFile) bool { return f.Name == name })
+	})
+
 	listmodels.Update(page.fileModel, slices.Values(status.Files))
+	page.updateFileButtons()
 	return true
 }
+
+// batchFiles returns the names of the files that batch operations
+// apply to: the selected files if there are any, or all of them
+// otherwise.
+func (page *SelfPage) batchFiles() []string {
+	names := make([]string, 0, len(page.files))
+	for _, f := range page.files {
+		if len(page.selectedFiles) == 0 || page.selectedFiles[f.Name] {
+			names = append(names, f.Name)
+		}
+	}
+	return names
+}
+
+func (page *SelfPage) updateFileButtons() {
+	page.SaveFilesButton.SetSensitive(len(page.files) > 0)
+	page.DeleteFilesButton.SetSensitive(len(page.files) > 0)
+
+	if len(page.selectedFiles) == 0 {
+		page.SaveFilesButton.SetTooltipText("Save all to folder…")
+		page.DeleteFilesButton.SetTooltipText("Delete all")
+		return
+	}
+	page.SaveFilesButton.SetTooltipText("Save selected to folder…")
+	page.DeleteFilesButton.SetTooltipText("Delete selected")
+}
+
+func (page *SelfPage) saveFiles() {
+	names := page.batchFiles()
+	if len(names) == 0 {
+		return
+	}
+
+	dialog := gtk.NewFileDialog()
+	dialog.SetModal(true)
+	dialog.SelectFolder(context.TODO(), page.app.window(), func(res gio.AsyncResulter) {
+		dir, err := dialog.SelectFolderFinish(res)
+		if err != nil {
+			if !gutil.ErrHasCode(err, int(gtk.DialogErrorDismissed)) {
+				slog.Error("select folder", "err", err)
+			}
+			return
+		}
+
+		page.app.saveFilesTo(context.TODO(), names, dir.Path())
+	})
+}
+
+func (page *SelfPage) deleteFiles() {
+	names := page.batchFiles()
+	if len(names) == 0 {
+		return
+	}
+
+	Confirmation{
+		Heading: fmt.Sprintf("Delete %v file(s)?", len(names)),
+		Body:    "If you delete these files, you will no longer be able to save them to your local machine.",
+		Accept:  "_Delete",
+		Reject:  "_Cancel",
+	}.Show(page.app, func(accept bool) {
+		if accept {
+			go page.app.deleteWaitingFiles(context.TODO(), names)
+		}
+	})
+}
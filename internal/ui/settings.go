@@ -2,20 +2,21 @@ package ui
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
 	"slices"
+	"strings"
 	"time"
 
+	"deedles.dev/trayscale/internal/archive"
+	"deedles.dev/trayscale/internal/giofs"
 	"deedles.dev/trayscale/internal/gutil"
 	"deedles.dev/trayscale/internal/metadata"
-	"deedles.dev/trayscale/internal/tsutil"
+	"deedles.dev/trayscale/internal/tray"
 	"deedles.dev/xiter"
 	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
 	"github.com/diamondburned/gotk4/pkg/gio/v2"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
-	"tailscale.com/ipn"
 )
 
 func (a *App) initSettings(ctx context.Context) {
@@ -39,10 +40,25 @@ func (a *App) initSettings(ctx context.Context) {
 				a.tray = nil
 			})
 
+		case "tray-icon-theme":
+			glib.IdleAdd(func() {
+				a.tray.SetIconTheme(a.trayIconTheme())
+			})
+
+		case "tray-primary-action", "tray-secondary-action":
+			glib.IdleAdd(func() {
+				a.tray.SetClickActions(a.trayClickActions())
+			})
+
 		case "polling-interval":
 			a.poller.SetInterval() <- a.getInterval()
 
-		case "taildrop-auto-save", "taildrop-auto-save-dir":
+		case "netcheck-interval":
+			glib.IdleAdd(a.scheduleNetCheck)
+
+		case "taildrop-auto-save", "taildrop-auto-save-dir", "taildrop-auto-save-rules", "taildrop-conflict-policy",
+			"taildrop-auto-save-max-size", "taildrop-auto-save-min-free", "taildrop-auto-save-confirm",
+			"taildrop-scan", "taildrop-scan-socket":
 			glib.IdleAdd(func() {
 				// New enable/dir selection should retry files that failed
 				// against the previous configuration.
@@ -61,37 +77,8 @@ func (a *App) runSettings(ctx context.Context) {
 			a.initTray(ctx)
 		})
 	}
-}
-
-func (a *App) showChangeControlServer() {
-	status := <-a.poller.GetIPN()
 
-	Prompt{
-		Heading: "Control Server URL",
-		Purpose: gtk.InputPurposeURL,
-		Responses: []PromptResponse{
-			{ID: "cancel", Label: "_Cancel"},
-			{ID: "default", Label: "Use _Default"},
-			{ID: "set", Label: "_Set URL", Appearance: adw.ResponseSuggested, Default: true},
-		},
-	}.Show(a, status.Prefs.ControlURL(), func(response, val string) {
-		switch response {
-		case "default":
-			val = ipn.DefaultControlURL
-			fallthrough // Oh my.
-		case "set":
-			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-			defer cancel()
-
-			err := tsutil.SetControlURL(ctx, val)
-			if err != nil {
-				slog.Error("update control plane server URL", "err", err, "url", val)
-				a.win.Toast(fmt.Sprintf("Error setting control URL: %v", err))
-				return
-			}
-			<-a.poller.Poll()
-		}
-	})
+	glib.IdleAdd(a.scheduleNetCheck)
 }
 
 func (a *App) showPreferences() {
@@ -102,8 +89,21 @@ func (a *App) showPreferences() {
 
 	dialog := NewPreferencesDialog()
 	a.settings.Bind("tray-icon", dialog.UseTrayIconRow.Object, "active", gio.SettingsBindDefault)
+	a.bindChoice(dialog.TrayIconThemeRow, "tray-icon-theme", []string{"system", "light", "dark"})
+	a.bindChoice(dialog.TrayPrimaryActionRow, "tray-primary-action", []string{"window", "connection", "exit-node", "menu"})
+	a.bindChoice(dialog.TraySecondaryActionRow, "tray-secondary-action", []string{"none", "window", "connection", "exit-node"})
 	a.settings.Bind("polling-interval", dialog.PollingIntervalAdjustment.Object, "value", gio.SettingsBindDefault)
+	a.settings.Bind("netcheck-interval", dialog.NetCheckIntervalAdjustment.Object, "value", gio.SettingsBindDefault)
+	a.settings.Bind("netcheck-on-link-change", dialog.NetCheckOnLinkChangeRow.Object, "active", gio.SettingsBindDefault)
 	a.settings.Bind("taildrop-auto-save", dialog.TaildropAutoSaveRow.Object, "active", gio.SettingsBindDefault)
+	a.bindChoice(dialog.TaildropConflictPolicyRow, "taildrop-conflict-policy", []string{"rename", "overwrite", "keep-newest", "skip", "skip-identical"})
+	a.settings.Bind("taildrop-extract-archives", dialog.TaildropExtractArchivesRow.Object, "active", gio.SettingsBindDefault)
+	a.bindChoice(dialog.TaildropArchiveFormatRow, "taildrop-archive-format", []string{"tar.zst", "tar.gz", "zip", "tar"})
+	a.settings.Bind("taildrop-archive-prescan", dialog.TaildropArchivePreScanRow.Object, "active", gio.SettingsBindDefault)
+	dialog.TaildropArchiveExcludeRow.SetText(strings.Join(a.settings.Strv("taildrop-archive-exclude"), ", "))
+	dialog.TaildropArchiveExcludeRow.ConnectApply(func() {
+		a.settings.SetStrv("taildrop-archive-exclude", splitList(dialog.TaildropArchiveExcludeRow.Text()))
+	})
 
 	updateAutoSaveSubtitle := func() {
 		dir := a.settings.String("taildrop-auto-save-dir")
@@ -146,6 +146,18 @@ func (a *App) showPreferences() {
 		})
 	}
 
+	a.settings.Bind("taildrop-auto-save-max-size", dialog.TaildropMaxSizeAdjustment.Object, "value", gio.SettingsBindDefault)
+	a.settings.Bind("taildrop-auto-save-min-free", dialog.TaildropMinFreeAdjustment.Object, "value", gio.SettingsBindDefault)
+	a.settings.Bind("taildrop-auto-save-confirm", dialog.TaildropConfirmRow.Object, "active", gio.SettingsBindDefault)
+	a.settings.Bind("taildrop-scan", dialog.TaildropScanRow.Object, "enable-expansion", gio.SettingsBindDefault)
+	dialog.TaildropScanSocketRow.SetText(a.settings.String("taildrop-scan-socket"))
+	dialog.TaildropScanSocketRow.ConnectApply(func() {
+		a.settings.SetString("taildrop-scan-socket", strings.TrimSpace(dialog.TaildropScanSocketRow.Text()))
+	})
+	a.bindChoice(dialog.TaildropScanActionRow, "taildrop-scan-action", []string{"quarantine", "delete"})
+	a.initAutoSaveRules(dialog)
+	a.initPostSaveHooks(dialog)
+
 	dialog.TaildropAutoSaveFolderButton.ConnectClicked(func() {
 		selectFolder(nil)
 	})
@@ -167,6 +179,25 @@ func (a *App) showPreferences() {
 	dialog.PreferencesDialog.Present(a.window())
 }
 
+// bindChoice keeps the selected item of row in sync with the string
+// setting key. values holds the setting value corresponding to each
+// item in row's model, in order.
+func (a *App) bindChoice(row *adw.ComboRow, key string, values []string) {
+	if i := slices.Index(values, a.settings.String(key)); i >= 0 {
+		row.SetSelected(uint(i))
+	}
+
+	row.NotifyProperty("selected", func() {
+		i := int(row.Selected())
+		if i < 0 || i >= len(values) {
+			return
+		}
+		if a.settings.String(key) != values[i] {
+			a.settings.SetString(key, values[i])
+		}
+	})
+}
+
 // showAbout shows the app's about dialog.
 func (a *App) showAbout() {
 	dialog := adw.NewAboutDialog()
@@ -190,9 +221,84 @@ func (a *App) showAbout() {
 	dialog.Present(a.window())
 }
 
+// trayIconTheme returns the tray icon variant selected in settings,
+// resolving "system" using the current style preference.
+func (a *App) trayIconTheme() tray.IconTheme {
+	theme := "system"
+	if a.settings != nil {
+		theme = a.settings.String("tray-icon-theme")
+	}
+
+	switch theme {
+	case "light":
+		return tray.IconThemeLight
+	case "dark":
+		return tray.IconThemeDark
+	}
+
+	if adw.StyleManagerGetDefault().Dark() {
+		return tray.IconThemeDark
+	}
+	return tray.IconThemeLight
+}
+
+// trayClickActions returns the primary and secondary tray icon click
+// actions selected in settings.
+func (a *App) trayClickActions() (primary, secondary tray.ClickAction) {
+	if a.settings == nil {
+		return tray.ClickToggleWindow, tray.ClickNone
+	}
+
+	parse := func(key string) tray.ClickAction {
+		switch a.settings.String(key) {
+		case "window":
+			return tray.ClickToggleWindow
+		case "connection":
+			return tray.ClickConnToggle
+		case "exit-node":
+			return tray.ClickExitToggle
+		case "menu":
+			return tray.ClickMenu
+		default:
+			return tray.ClickNone
+		}
+	}
+	return parse("tray-primary-action"), parse("tray-secondary-action")
+}
+
 func (a *App) getInterval() time.Duration {
 	if a.settings == nil {
 		return 5 * time.Second
 	}
 	return time.Duration(a.settings.Double("polling-interval") * float64(time.Second))
 }
+
+// splitList splits a comma-separated list entered by the user,
+// trimming whitespace and dropping empty elements.
+func splitList(str string) []string {
+	var list []string
+	for item := range strings.SplitSeq(str, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// archiveOptions returns the options used to pack directories for
+// sending.
+func (a *App) archiveOptions() giofs.Options {
+	if a.settings == nil {
+		return giofs.Options{Options: archive.Options{Format: archive.FormatTarZstd}}
+	}
+
+	format, _ := archive.ParseFormat(a.settings.String("taildrop-archive-format"))
+	return giofs.Options{
+		Options: archive.Options{
+			Format:  format,
+			Exclude: a.settings.Strv("taildrop-archive-exclude"),
+		},
+		PreScan: a.settings.Boolean("taildrop-archive-prescan"),
+	}
+}
@@ -25,10 +25,6 @@ var (
 		)
 	}))
 
-	stringListSorter = gtk.NewCustomSorter(glib.NewObjectComparer(func(s1, s2 *gtk.StringObject) int {
-		return cmp.Compare(s1.String(), s2.String())
-	}))
-
 	boolTrueIcon    = gio.NewThemedIconWithDefaultFallbacks("emblem-ok-symbolic")
 	boolFalseIcon   = gio.NewThemedIconWithDefaultFallbacks("window-close-symbolic")
 	boolUnknownIcon = gio.NewThemedIconWithDefaultFallbacks("dialog-question-symbolic")